@@ -0,0 +1,96 @@
+package aggregate
+
+import (
+	"fmt"
+	"math"
+)
+
+// Strategy combines the per-input recommendations of a check into one
+type Strategy string
+
+const (
+	// Max takes the highest recommendation, so no input is short of replicas
+	Max Strategy = "max"
+	// Min takes the lowest recommendation
+	Min Strategy = "min"
+	// Mean takes the arithmetic mean of the recommendations
+	Mean Strategy = "mean"
+	// GeometricMean takes the geometric mean, which dampens a single outlier
+	GeometricMean Strategy = "geometric_mean"
+	// WeightedSum adds up the recommendations multiplied by their weights
+	WeightedSum Strategy = "weighted_sum"
+)
+
+// Default is used when a check doesn't name a strategy
+const Default = Max
+
+// Strategies lists every supported strategy
+func Strategies() []Strategy {
+	return []Strategy{Max, Min, Mean, GeometricMean, WeightedSum}
+}
+
+// Parse reads a strategy name, returning Default for an empty one
+func Parse(s string) (Strategy, error) {
+	if s == "" {
+		return Default, nil
+	}
+	for _, strategy := range Strategies() {
+		if Strategy(s) == strategy {
+			return strategy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown aggregation %q, must be one of %v", s, Strategies())
+}
+
+// Value is the recommendation computed from a single input, e.g. a resource
+type Value struct {
+	Name     string
+	Replicas float64
+	// Weight is only used by WeightedSum
+	Weight float64
+}
+
+// Combine reduces the values to a single recommendation, zero when there are
+// none.  An unknown strategy falls back to Default.
+func Combine(strategy Strategy, values []Value) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	switch strategy {
+	case Min:
+		combined := values[0].Replicas
+		for _, v := range values[1:] {
+			combined = math.Min(combined, v.Replicas)
+		}
+		return combined
+	case Mean:
+		var sum float64
+		for _, v := range values {
+			sum += v.Replicas
+		}
+		return sum / float64(len(values))
+	case GeometricMean:
+		// Summing logarithms keeps large products from overflowing
+		var logSum float64
+		for _, v := range values {
+			if v.Replicas <= 0 {
+				return 0
+			}
+			logSum += math.Log(v.Replicas)
+		}
+		return math.Exp(logSum / float64(len(values)))
+	case WeightedSum:
+		var sum float64
+		for _, v := range values {
+			sum += v.Replicas * v.Weight
+		}
+		return sum
+	}
+
+	combined := values[0].Replicas
+	for _, v := range values[1:] {
+		combined = math.Max(combined, v.Replicas)
+	}
+	return combined
+}
@@ -0,0 +1,64 @@
+package aggregate_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/aggregate"
+)
+
+func GiveMeValues(replicas ...float64) []aggregate.Value {
+	var values []aggregate.Value
+	for i, r := range replicas {
+		values = append(values, aggregate.Value{Name: string(rune('a' + i)), Replicas: r, Weight: 1})
+	}
+	return values
+}
+
+func TestCombine(t *testing.T) {
+	weighted := []aggregate.Value{
+		{Name: "cpu", Replicas: 10, Weight: 0.75},
+		{Name: "memory", Replicas: 20, Weight: 0.25},
+	}
+
+	tests := []struct {
+		name     string
+		strategy aggregate.Strategy
+		values   []aggregate.Value
+		want     float64
+	}{
+		{"nothing", aggregate.Max, nil, 0},
+		{"max", aggregate.Max, GiveMeValues(4, 9, 1), 9},
+		{"default is max", "", GiveMeValues(4, 9, 1), 9},
+		{"min", aggregate.Min, GiveMeValues(4, 9, 1), 1},
+		{"mean", aggregate.Mean, GiveMeValues(4, 9, 2), 5},
+		{"geometric mean", aggregate.GeometricMean, GiveMeValues(4, 9), 6},
+		{"geometric mean of one", aggregate.GeometricMean, GiveMeValues(7), 7},
+		{"geometric mean with zero", aggregate.GeometricMean, GiveMeValues(4, 0), 0},
+		{"geometric mean of huge values", aggregate.GeometricMean, GiveMeValues(1e200, 1e200), 1e200},
+		{"weighted sum", aggregate.WeightedSum, weighted, 12.5},
+		{"weighted sum without weights", aggregate.WeightedSum, []aggregate.Value{{Replicas: 3}, {Replicas: 4}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aggregate.Combine(tt.strategy, tt.values)
+			if math.Abs(got-tt.want) > 1e-9*math.Max(1, tt.want) {
+				t.Errorf("Combine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range aggregate.Strategies() {
+		if got, err := aggregate.Parse(string(s)); err != nil || got != s {
+			t.Errorf("Parse(%q) = %v, %v", s, got, err)
+		}
+	}
+	if got, err := aggregate.Parse(""); err != nil || got != aggregate.Default {
+		t.Errorf("Parse(\"\") = %v, %v, want the default", got, err)
+	}
+	if _, err := aggregate.Parse("median"); err == nil {
+		t.Errorf("Parse(median) should fail")
+	}
+}
@@ -0,0 +1,195 @@
+package annotation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/ryanmt/cluster-resource-autoscaler/aggregate"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/kubeapi"
+	"github.com/ryanmt/cluster-resource-autoscaler/logging"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Prefix of every annotation read from workloads
+const Prefix = "cluster-resource-autoscaler/"
+
+const (
+	CPUPerReplica    = Prefix + "cpu-per-replica"
+	MemoryPerReplica = Prefix + "memory-per-replica"
+	// PerReplica holds any other resources as a comma separated list, e.g.
+	// "ephemeral-storage=10Gi,example.com/fpga=1"
+	PerReplica = Prefix + "per-replica"
+	// NodesPerReplica wants a replica per so many eligible nodes
+	NodesPerReplica = Prefix + "nodes-per-replica"
+	// NodeSelector limits the check to nodes matching a label selector, e.g. "pool=batch"
+	NodeSelector = Prefix + "node-selector"
+	// Aggregation combines the recommendations of each resource, e.g. "geometric_mean"
+	Aggregation = Prefix + "aggregation"
+	// MinReplicas and MaxReplicas bound the recommendation
+	MinReplicas = Prefix + "min-replicas"
+	MaxReplicas = Prefix + "max-replicas"
+	// Name of the check, defaults to DefaultName
+	Name = Prefix + "name"
+)
+
+// DefaultName is the name of a check discovered from annotations without a name
+const DefaultName = "annotations"
+
+var logger logr.Logger = logr.Discard()
+var ctx context.Context
+
+func Init(initCtx context.Context) {
+	logger = logging.FromContextOrDiscard(initCtx)
+	ctx = initCtx
+}
+
+// Discover lists every Deployment, StatefulSet and ReplicaSet in the cluster
+// and builds a check for each one that opted in through annotations.  It runs
+// on every tick, so adding or removing annotations takes effect without a
+// restart.  Workloads with invalid annotations are logged and skipped.
+func Discover() ([]check.Spec, error) {
+	var workloads []workload
+
+	deployments, err := kubeapi.APIClient().AppsV1().Deployments(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		workloads = append(workloads, workload{"deployment", &deployments.Items[i].ObjectMeta})
+	}
+
+	statefulSets, err := kubeapi.APIClient().AppsV1().StatefulSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		workloads = append(workloads, workload{"statefulset", &statefulSets.Items[i].ObjectMeta})
+	}
+
+	replicaSets, err := kubeapi.APIClient().AppsV1().ReplicaSets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i].ObjectMeta
+		// Deployments, Argo Rollouts and the like copy their annotations
+		// onto their ReplicaSets, which must be left for them to scale
+		if metav1.GetControllerOf(rs) != nil {
+			continue
+		}
+		workloads = append(workloads, workload{"replicaset", rs})
+	}
+
+	var specs []check.Spec
+	for _, w := range workloads {
+		spec, ok, err := FromAnnotations(w.kind, w.meta)
+		if err != nil {
+			logger.Error(err, "Invalid autoscaling annotations, skipping", "kind", w.kind, "namespace", w.meta.Namespace, "name", w.meta.Name)
+			continue
+		}
+		if ok {
+			specs = append(specs, spec)
+		}
+	}
+	logger.V(2).Info("Discovered annotated targets", "count", len(specs))
+
+	return specs, nil
+}
+
+type workload struct {
+	kind string
+	meta *metav1.ObjectMeta
+}
+
+// FromAnnotations builds the check described by a workload's annotations.  ok
+// is false when the workload carries no per-replica annotation.
+func FromAnnotations(kind string, meta *metav1.ObjectMeta) (spec check.Spec, ok bool, err error) {
+	cpu, hasCPU := meta.Annotations[CPUPerReplica]
+	memory, hasMemory := meta.Annotations[MemoryPerReplica]
+	perReplica, hasPerReplica := meta.Annotations[PerReplica]
+	nodes, hasNodes := meta.Annotations[NodesPerReplica]
+	if !hasCPU && !hasMemory && !hasPerReplica && !hasNodes {
+		return spec, false, nil
+	}
+
+	spec = check.Spec{
+		Name:   DefaultName,
+		Target: check.ScalingTarget{Kind: kind, Name: meta.Name, Namespace: meta.Namespace},
+		Source: fmt.Sprintf("annotations/%s/%s/%s", kind, meta.Namespace, meta.Name),
+	}
+	if name, ok := meta.Annotations[Name]; ok {
+		spec.Name = name
+	}
+	spec.Nodes.Selector = meta.Annotations[NodeSelector]
+	spec.Aggregation = aggregate.Strategy(meta.Annotations[Aggregation])
+
+	if hasCPU {
+		if spec.CPUPerReplica, err = resource.ParseQuantity(cpu); err != nil {
+			return spec, true, fmt.Errorf("%s: %w", CPUPerReplica, err)
+		}
+	}
+	if hasMemory {
+		if spec.MemoryPerReplica, err = resource.ParseQuantity(memory); err != nil {
+			return spec, true, fmt.Errorf("%s: %w", MemoryPerReplica, err)
+		}
+	}
+	if hasPerReplica {
+		if spec.PerReplica, err = parseResourceList(perReplica); err != nil {
+			return spec, true, fmt.Errorf("%s: %w", PerReplica, err)
+		}
+	}
+	if hasNodes {
+		if spec.NodesPerReplica, err = resource.ParseQuantity(nodes); err != nil {
+			return spec, true, fmt.Errorf("%s: %w", NodesPerReplica, err)
+		}
+	}
+	if spec.MinReplicas, err = parseReplicas(meta.Annotations, MinReplicas); err != nil {
+		return spec, true, err
+	}
+	if spec.MaxReplicas, err = parseReplicas(meta.Annotations, MaxReplicas); err != nil {
+		return spec, true, err
+	}
+
+	return spec, true, check.Validate([]check.Spec{spec})
+}
+
+// parseReplicas parses an optional replica count annotation
+func parseReplicas(annotations map[string]string, key string) (*int32, error) {
+	value, ok := annotations[key]
+	if !ok {
+		return nil, nil
+	}
+	replicas, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	r := int32(replicas)
+	return &r, nil
+}
+
+// parseResourceList parses "name=quantity" pairs separated by commas
+func parseResourceList(value string) (map[corev1.ResourceName]resource.Quantity, error) {
+	list := make(map[corev1.ResourceName]resource.Quantity)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected resource=quantity, got %q", pair)
+		}
+		q, err := resource.ParseQuantity(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", parts[0], err)
+		}
+		list[corev1.ResourceName(strings.TrimSpace(parts[0]))] = q
+	}
+	return list, nil
+}
@@ -0,0 +1,100 @@
+package annotation_test
+
+import (
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/annotation"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func GiveMeAWorkload(annotations map[string]string) *metav1.ObjectMeta {
+	return &metav1.ObjectMeta{Name: "nginx", Namespace: "default", Annotations: annotations}
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        check.Spec
+		wantOk      bool
+		wantErr     bool
+	}{
+		{"not opted in", map[string]string{"unrelated": "16"}, check.Spec{}, false, false},
+		{
+			"cpu",
+			map[string]string{annotation.CPUPerReplica: "16"},
+			check.Spec{Name: annotation.DefaultName, CPUPerReplica: resource.MustParse("16")},
+			true, false,
+		},
+		{
+			"named cpu and memory",
+			map[string]string{annotation.CPUPerReplica: "16", annotation.MemoryPerReplica: "100Gi", annotation.Name: "edge"},
+			check.Spec{Name: "edge", CPUPerReplica: resource.MustParse("16"), MemoryPerReplica: resource.MustParse("100Gi")},
+			true, false,
+		},
+		{
+			"other resources",
+			map[string]string{annotation.PerReplica: "ephemeral-storage=10Gi, example.com/fpga=1"},
+			check.Spec{Name: annotation.DefaultName, PerReplica: map[corev1.ResourceName]resource.Quantity{
+				corev1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+				"example.com/fpga":              resource.MustParse("1"),
+			}},
+			true, false,
+		},
+		{
+			"per node agent",
+			map[string]string{annotation.NodesPerReplica: "10"},
+			check.Spec{Name: annotation.DefaultName, NodesPerReplica: resource.MustParse("10")},
+			true, false,
+		},
+		{
+			"node pool",
+			map[string]string{annotation.CPUPerReplica: "16", annotation.NodeSelector: "pool=batch"},
+			check.Spec{Name: annotation.DefaultName, CPUPerReplica: resource.MustParse("16"), Nodes: check.NodeFilter{Selector: "pool=batch"}},
+			true, false,
+		},
+		{
+			"bounded",
+			map[string]string{annotation.CPUPerReplica: "16", annotation.MinReplicas: "2", annotation.MaxReplicas: "10"},
+			check.Spec{Name: annotation.DefaultName, CPUPerReplica: resource.MustParse("16"), MinReplicas: int32Ptr(2), MaxReplicas: int32Ptr(10)},
+			true, false,
+		},
+		{"bad bound", map[string]string{annotation.CPUPerReplica: "16", annotation.MaxReplicas: "ten"}, check.Spec{}, true, true},
+		{"crossed bounds", map[string]string{annotation.CPUPerReplica: "16", annotation.MinReplicas: "10", annotation.MaxReplicas: "2"}, check.Spec{}, true, true},
+		{"bad node selector", map[string]string{annotation.CPUPerReplica: "16", annotation.NodeSelector: "pool in (batch"}, check.Spec{}, true, true},
+		{"not a number", map[string]string{annotation.CPUPerReplica: "sixteen"}, check.Spec{}, true, true},
+		{"not a pair", map[string]string{annotation.PerReplica: "ephemeral-storage"}, check.Spec{}, true, true},
+		{"negative", map[string]string{annotation.MemoryPerReplica: "-1"}, check.Spec{}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := annotation.FromAnnotations("deployment", GiveMeAWorkload(tt.annotations))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOk {
+				t.Fatalf("FromAnnotations() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok || tt.wantErr {
+				return
+			}
+			if got.Name != tt.want.Name || got.CPUPerReplica.Cmp(tt.want.CPUPerReplica) != 0 || got.MemoryPerReplica.Cmp(tt.want.MemoryPerReplica) != 0 ||
+				got.NodesPerReplica.Cmp(tt.want.NodesPerReplica) != 0 ||
+				!equality.Semantic.DeepEqual(got.PerReplica, tt.want.PerReplica) || got.Nodes.Selector != tt.want.Nodes.Selector ||
+				!equality.Semantic.DeepEqual(got.MinReplicas, tt.want.MinReplicas) || !equality.Semantic.DeepEqual(got.MaxReplicas, tt.want.MaxReplicas) {
+				t.Errorf("FromAnnotations() = %+v, want %+v", got, tt.want)
+			}
+			if got.TargetKey() != "deployment->default/nginx" {
+				t.Errorf("FromAnnotations() target = %v", got.TargetKey())
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package v1alpha1 contains the ScalingPolicy API, a namespaced replacement for
+// the centrally managed config.json.
+package v1alpha1
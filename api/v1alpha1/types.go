@@ -0,0 +1,69 @@
+package v1alpha1
+
+import (
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScalingPolicy is a single check owned by the team running the target.  The
+// spec is the same check.Spec that config.json entries decode into.
+type ScalingPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   check.Spec          `json:"spec"`
+	Status ScalingPolicyStatus `json:"status,omitempty"`
+}
+
+// ScalingPolicyStatus reports what the controller did with the policy on its last tick
+type ScalingPolicyStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	LastUpdateTime     metav1.Time `json:"lastUpdateTime,omitempty"`
+	CurrentReplicas    int32       `json:"currentReplicas,omitempty"`
+	// LastRecommendation is the replica count computed from cluster capacity,
+	// combined across every check of the target
+	LastRecommendation int32 `json:"lastRecommendation,omitempty"`
+	// Checks lists every check which contributed to LastRecommendation,
+	// including those configured by other policies or config.json
+	Checks []CheckStatus `json:"checks,omitempty"`
+	// LastError is empty when the last reconcile succeeded
+	LastError string `json:"lastError,omitempty"`
+	// HPA names the HorizontalPodAutoscaler whose minReplicas is set to
+	// LastRecommendation, empty when the target is scaled directly
+	HPA string `json:"hpa,omitempty"`
+	// Zones reports each target of a per zone policy in place of the fields
+	// above
+	Zones []ZoneStatus `json:"zones,omitempty"`
+}
+
+// ZoneStatus is what happened to the target of a single zone
+type ZoneStatus struct {
+	Zone               string `json:"zone"`
+	Target             string `json:"target"`
+	CurrentReplicas    int32  `json:"currentReplicas,omitempty"`
+	LastRecommendation int32  `json:"lastRecommendation,omitempty"`
+	LastError          string `json:"lastError,omitempty"`
+}
+
+// CheckStatus is the recommendation of a single check of the target
+type CheckStatus struct {
+	Name               string `json:"name"`
+	Source             string `json:"source,omitempty"`
+	LastRecommendation int32  `json:"lastRecommendation"`
+	// Bound is minReplicas or maxReplicas while that bound holds
+	// LastRecommendation, empty otherwise
+	Bound string `json:"bound,omitempty"`
+	// HeldBy is tolerance, scaleDownStabilization or rateLimit while one of
+	// them keeps LastRecommendation from following capacity, empty otherwise
+	HeldBy string `json:"heldBy,omitempty"`
+	// Schedule names the check's active schedule, empty when none is
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// ScalingPolicyList is a list of ScalingPolicies
+type ScalingPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ScalingPolicy `json:"items"`
+}
@@ -0,0 +1,74 @@
+package check
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// CapacitySource is what a check measures the cluster's capacity by
+type CapacitySource string
+
+const (
+	// CapacityAllocatable sums the allocatable resources of the nodes
+	CapacityAllocatable CapacitySource = "allocatable"
+	// CapacityUsage sums the usage of the nodes reported by metrics.k8s.io,
+	// falling back to allocatable when metrics are missing or stale
+	CapacityUsage CapacitySource = "usage"
+	// CapacityRequests sums the requests of the pods scheduled onto the nodes
+	CapacityRequests CapacitySource = "requests"
+)
+
+// CapacitySources lists every supported source, the first one being the default
+func CapacitySources() []CapacitySource {
+	return []CapacitySource{CapacityAllocatable, CapacityUsage, CapacityRequests}
+}
+
+// UsageResources are the resources node metrics report the usage of, i.e. the
+// only ones a check can scale on with CapacityUsage
+func UsageResources() []v1.ResourceName {
+	return []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+}
+
+// DefaultMetricsMaxAge is how old node metrics may be before they are stale
+const DefaultMetricsMaxAge = 5 * time.Minute
+
+// CapacitySource is the check's source of capacity, allocatable when unset
+func (s *Spec) CapacitySource() CapacitySource {
+	if s.Capacity == "" {
+		return CapacityAllocatable
+	}
+	return s.Capacity
+}
+
+// MaxMetricsAge is how old node metrics may be before the check falls back
+// to allocatable
+func (s *Spec) MaxMetricsAge() time.Duration {
+	if s.MetricsMaxAge.Duration == 0 {
+		return DefaultMetricsMaxAge
+	}
+	return s.MetricsMaxAge.Duration
+}
+
+func containsCapacitySource(list []CapacitySource, source CapacitySource) bool {
+	for _, s := range list {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// PodFilter narrows the pods whose requests count in requests capacity.  The
+// zero value matches every pod.
+type PodFilter struct {
+	// Namespace of the pods, every namespace when empty
+	Namespace string `json:"namespace,omitempty"`
+	// Selector is a label selector, e.g. "app!=batch"
+	Selector string `json:"selector,omitempty"`
+}
+
+// IsZero reports whether the filter matches every pod
+func (f *PodFilter) IsZero() bool {
+	return f.Namespace == "" && f.Selector == ""
+}
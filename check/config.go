@@ -1,18 +1,26 @@
 package check
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/go-logr/logr"
+	"github.com/ryanmt/cluster-resource-autoscaler/aggregate"
 	"github.com/ryanmt/cluster-resource-autoscaler/logging"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-var logger logr.Logger
+var logger logr.Logger = logr.Discard()
 
 // Init configures our hooks for a logger
 func Init(ctx context.Context) {
@@ -21,90 +29,378 @@ func Init(ctx context.Context) {
 
 // Support checks from CM :done:
 // Support checks from JSON :done:
-func FromFile(jsonFile string) ([]Spec, error) {
-	file, err := os.Open(jsonFile)
+// Support checks from YAML :done:
+
+// FromFile reads checks from a JSON or YAML file, or from every config file in
+// a directory.  The format is taken from the extension, falling back to the
+// content when the extension is unknown.
+func FromFile(path string) ([]Spec, error) {
+	return fromPath(path, nil)
+}
+
+// FromReader decodes a JSON array of checks.  Every check is validated and the
+// whole array is rejected with a ValidationError if any of them is invalid.
+func FromReader(r io.Reader) ([]Spec, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
 
-	return FromReader(file)
+	return finish(decodeJSON(data, ""))
 }
 
-func FromReader(r io.Reader) ([]Spec, error) {
-	var specList []Spec
-	var err error
+// decodeJSON decodes a JSON array of checks, recording where each one starts
+func decodeJSON(data []byte, file string) ([]entry, []FieldError) {
+	var entries []entry
+	var problems []FieldError
 
-	d := json.NewDecoder(r)
+	d := json.NewDecoder(bytes.NewReader(data))
 
 	// Read opening bracket
-	_, err = d.Token()
+	tok, err := d.Token()
 	if err != nil {
-		return specList, err
+		return nil, []FieldError{syntaxProblem(data, file, err)}
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, []FieldError{{Position: positionAt(data, file, 0), Message: "expected a JSON array of checks"}}
 	}
-
-	depUnique := make(map[string]bool)
 
 	// TODO: Not sure if the the streaming version is a good idea in complexity...
 	// just feels safer to model a bounded JSON parsing implementation
-	for d.More() {
-		var s Spec
-		err := d.Decode(&s)
+	for i := 0; d.More(); i++ {
+		start := skipSeparators(data, d.InputOffset())
+		pos := positionAt(data, file, start)
+		pos.Index = i
+
+		var raw json.RawMessage
+		err := d.Decode(&raw)
 		if err != nil {
-			// Blah blah blah
+			// Nothing past a syntax error can be trusted
 			logger.Error(err, "Error decoding configuration")
-			return specList, err
+			return entries, append(problems, syntaxProblem(data, file, err))
 		}
 
-		if _, ok := depUnique[s.TargetKey()]; ok {
-			// We've already seen this key which is a bad configuration.  Probs should error or something but RN this is just a info statement. :|
-			logger.Info("Already have a configuration, skipping...", "deploymentKey", s.TargetKey(), "checkSpec", s.Name)
-			continue
-		} else {
-			depUnique[s.TargetKey()] = true
-		}
-		specList = append(specList, s)
+		index := i
+		spec, specProblems := decodeSpec(raw, func(offset int64) Position {
+			p := positionAt(data, file, start+offset)
+			p.Index = index
+			return p
+		})
+		problems = append(problems, specProblems...)
+		entries = append(entries, entry{Spec: spec, Position: pos})
 	}
 	_, err = d.Token() // Should just be the final bracket
 	if err != nil {
-		return specList, err
+		return entries, append(problems, syntaxProblem(data, file, err))
 	}
 
-	return specList, nil
+	return entries, problems
+}
+
+// decodeSpec strictly decodes a single JSON check, reporting unknown keys and
+// type mismatches.  locate maps an offset within raw to a Position.
+func decodeSpec(raw []byte, locate func(offset int64) Position) (Spec, []FieldError) {
+	var s Spec
+	var problems []FieldError
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return s, []FieldError{{Position: locate(0), Message: err.Error()}}
+	}
+	for _, field := range unknownFields(generic, reflect.TypeOf(s), "") {
+		problems = append(problems, FieldError{Position: locate(0), Field: field, Message: "unknown field"})
+	}
+
+	if err := json.Unmarshal(raw, &s); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			problems = append(problems, FieldError{
+				Position: locate(typeErr.Offset),
+				Field:    typeErr.Field,
+				Message:  fmt.Sprintf("cannot use %s as %s", typeErr.Value, typeErr.Type),
+			})
+		} else {
+			problems = append(problems, fieldDecodeProblems(generic, locate(0), err)...)
+		}
+	}
+
+	return s, problems
+}
+
+// SpecFromJSON strictly decodes a single check found outside config files,
+// e.g. the spec of a ScalingPolicy, reporting unknown keys and malformed
+// values against source as a ValidationError.  The check isn't validated.
+func SpecFromJSON(raw []byte, source string) (Spec, error) {
+	pos := Position{File: source}
+	spec, problems := decodeSpec(raw, func(int64) Position { return pos })
+	if len(problems) > 0 {
+		return spec, ValidationError(problems)
+	}
+	return spec, nil
+}
+
+// fieldDecodeProblems attributes an error from a type which decodes itself,
+// e.g. a malformed quantity, to the key(s) holding the bad value
+func fieldDecodeProblems(generic interface{}, pos Position, err error) []FieldError {
+	var problems []FieldError
+
+	obj, _ := generic.(map[string]interface{})
+	fields := jsonFields(reflect.TypeOf(Spec{}))
+	for _, key := range sortedKeys(obj) {
+		f, ok := fields[strings.ToLower(key)]
+		if !ok {
+			continue
+		}
+		value, _ := json.Marshal(obj[key])
+		if fieldErr := json.Unmarshal(value, reflect.New(f.Type).Interface()); fieldErr != nil {
+			problems = append(problems, FieldError{Position: pos, Field: key, Message: fieldErr.Error()})
+		}
+	}
+
+	if len(problems) == 0 {
+		problems = append(problems, FieldError{Position: pos, Message: err.Error()})
+	}
+	return problems
+}
+
+func syntaxProblem(data []byte, file string, err error) FieldError {
+	offset := int64(len(data))
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+	return FieldError{Position: positionAt(data, file, offset), Message: err.Error()}
+}
+
+// skipSeparators advances past the whitespace and comma the decoder leaves
+// between array elements, so the offset points at the element itself
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) {
+		switch data[offset] {
+		case ' ', '\t', '\r', '\n', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+// positionAt converts a byte offset into a 1-based line and column
+func positionAt(data []byte, file string, offset int64) Position {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return Position{File: file, Line: line, Column: column}
 }
 
 type ScalingTarget struct {
-	Name      string
-	Namespace string
-	Kind      string
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Kind is one of SupportedKinds or their short names, e.g. deploy, or with
+	// an APIVersion any kind exposing the scale subresource, e.g. Rollout
+	Kind string `json:"kind"`
+	// APIVersion of Kind, e.g. "argoproj.io/v1alpha1", the apps group when unset
+	APIVersion string `json:"apiVersion,omitempty"`
 }
 
-// Key generates a unique string for comparing between Spec targets
+// Key generates a unique string for comparing between Spec targets.  Kinds of
+// the apps group are keyed the same however they are named.
 func (s *ScalingTarget) Key() string {
-	return fmt.Sprintf("%s->%s/%s", s.Kind, s.Namespace, s.Name)
+	kind := s.NormalizedKind()
+	if group := s.Group(); group != "apps" && group != "" {
+		kind += "." + group
+	}
+	return fmt.Sprintf("%s->%s/%s", kind, s.Namespace, s.Name)
 }
 
+// Spec is a single scaling check.  Field names are matched case-insensitively
+// when decoding config.json, so both `CPUPerReplica` and `cpuPerReplica` work;
+// the ScalingPolicy CRD uses the camelCase form.
 type Spec struct {
-	CPUPerReplica    float64 // In millicores
-	MemoryPerReplica float64 // In bytes
-	Name             string  // A defined name for this scaling configuration
-	// ResourcePerReplica string        // How much resource per replica of the deployment
-	Target ScalingTarget // What deployment to scale
+	CPUPerReplica    resource.Quantity `json:"cpuPerReplica,omitempty"`    // In cores, e.g. "16" or "500m"
+	MemoryPerReplica resource.Quantity `json:"memoryPerReplica,omitempty"` // In bytes, e.g. "100Gi" or 100e9
+	Name             string            `json:"name,omitempty"`             // A defined name for this scaling configuration
+	// PerReplica is how much of any node allocatable resource warrants a
+	// replica, e.g. ephemeral-storage, hugepages-2Mi, pods or example.com/fpga.
+	// The prefix key `hugepages-` covers every huge page size at once.
+	PerReplica map[v1.ResourceName]resource.Quantity `json:"perReplica,omitempty"`
+	Target     ScalingTarget                         `json:"target"` // What deployment to scale
+	// Nodes limits the capacity and usage to the matching nodes, every node
+	// in the cluster when empty
+	Nodes NodeFilter `json:"nodes,omitempty"`
+	// PerZone splits the nodes by topology.kubernetes.io/zone and scales a
+	// target per zone, named by replacing ZonePlaceholder in Target.Name
+	PerZone bool `json:"perZone,omitempty"`
+	// Eligibility drops nodes, such as cordoned ones, from the nodes passing
+	// Nodes before anything is counted
+	Eligibility Eligibility `json:"eligibility,omitempty"`
+	// NodesPerReplica is how many eligible nodes warrant a replica,
+	// e.g. for per-node agents such as log aggregators
+	NodesPerReplica resource.Quantity `json:"nodesPerReplica,omitempty"`
+	// SchedulablePodsPerReplica is how many pods the eligible nodes
+	// can hold, going by their `pods` allocatable, to warrant a replica
+	SchedulablePodsPerReplica resource.Quantity `json:"schedulablePodsPerReplica,omitempty"`
+	// Capacity is what the per-replica values and ladder thresholds are
+	// measured against, allocatable when unset
+	Capacity CapacitySource `json:"capacity,omitempty"`
+	// MetricsMaxAge is how old node metrics may be in usage capacity,
+	// DefaultMetricsMaxAge when unset
+	MetricsMaxAge metav1.Duration `json:"metricsMaxAge,omitempty"`
+	// Pods limits the pods counted in requests capacity, every pod when empty
+	Pods PodFilter `json:"pods,omitempty"`
+	// Prediction scales for the capacity forecast a lead time ahead rather
+	// than the capacity measured, when set
+	Prediction *Prediction `json:"prediction,omitempty"`
+	// Mode is how capacity turns into replicas, linear when unset
+	Mode Mode `json:"mode,omitempty"`
+	// Ladder is the step function used in ladder mode
+	Ladder *Ladder `json:"ladder,omitempty"`
+	// Aggregation combines the recommendations of each resource, "max" when unset
+	Aggregation aggregate.Strategy `json:"aggregation,omitempty"`
+	// Weights of each input for the weighted_sum aggregation, 1 when unset
+	Weights map[v1.ResourceName]float64 `json:"weights,omitempty"`
+	// Rounding turns the aggregated recommendation into replicas, rounding up
+	// when unset
+	Rounding *Rounding `json:"rounding,omitempty"`
+	// Tolerance ignores recommendations within this fraction of the current
+	// replicas, e.g. 0.1 to ignore changes under 10%
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// ScaleDownStabilization scales down only as far as the highest
+	// recommendation seen over this window, e.g. "5m"
+	ScaleDownStabilization metav1.Duration `json:"scaleDownStabilization,omitempty"`
+	// ScaleUp and ScaleDown limit how fast the check scales the target
+	ScaleUp   *RateRules `json:"scaleUp,omitempty"`
+	ScaleDown *RateRules `json:"scaleDown,omitempty"`
+	// Schedules override the replica bounds or scale the recommendation at
+	// certain times, the first active one applying
+	Schedules []Schedule `json:"schedules,omitempty"`
+	// HPA manages the floor of the target's HorizontalPodAutoscaler with the
+	// recommendation rather than scaling the target, when set
+	HPA *HPAFloor `json:"hpa,omitempty"`
+	// MinReplicas is the fewest replicas the check recommends, DefaultMinReplicas when unset
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the most replicas the check recommends, unbounded when unset
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
 	// TargetUtilization  float64       // Target utilization for the resourceName
+
+	// Source records where the spec was loaded from, e.g. a file or a
+	// ScalingPolicy, so results can be reported back to it
+	Source string `json:"-"`
+	// Zone is set on each check a per zone check is split into
+	Zone string `json:"-"`
+}
+
+// DefaultMinReplicas keeps a check from scaling its target to zero when no
+// capacity is found, e.g. while every node is being replaced
+const DefaultMinReplicas int32 = 1
+
+// Bound names the limit which changed a recommendation
+type Bound string
+
+const (
+	BoundNone Bound = ""
+	BoundMin  Bound = "minReplicas"
+	BoundMax  Bound = "maxReplicas"
+)
+
+// Clamp limits a recommendation to the check's replica bounds, returning the
+// bound which was applied, if any
+func (s *Spec) Clamp(replicas int64) (int32, Bound) {
+	min := DefaultMinReplicas
+	if s.MinReplicas != nil {
+		min = *s.MinReplicas
+	}
+	max := int32(math.MaxInt32)
+	if s.MaxReplicas != nil {
+		max = *s.MaxReplicas
+	}
+
+	switch {
+	case replicas < int64(min):
+		return min, BoundMin
+	case replicas > int64(max):
+		return max, BoundMax
+	}
+	return int32(replicas), BoundNone
+}
+
+// Inputs other than resources which a check can scale on.  They share the
+// namespace of resource names, e.g. as keys of Weights.
+const (
+	InputNodes           v1.ResourceName = "nodes"
+	InputSchedulablePods v1.ResourceName = "schedulablePods"
+)
+
+// Inputs lists the resources and other inputs the check scales on
+func (s *Spec) Inputs() []v1.ResourceName {
+	inputs := s.Resources()
+	if !s.NodesPerReplica.IsZero() {
+		inputs = append(inputs, InputNodes)
+	}
+	if !s.SchedulablePodsPerReplica.IsZero() {
+		inputs = append(inputs, InputSchedulablePods)
+	}
+	return inputs
+}
+
+// Weight of an input for the weighted_sum aggregation
+func (s *Spec) Weight(rName v1.ResourceName) float64 {
+	if w, ok := s.Weights[rName]; ok {
+		return w
+	}
+	return 1
+}
+
+// Tolerates reports whether desired replicas are close enough to the current
+// ones to leave the target alone
+func (s *Spec) Tolerates(current, desired int32) bool {
+	if current == desired {
+		return true
+	}
+	if current == 0 {
+		return false
+	}
+	change := math.Abs(float64(desired)-float64(current)) / float64(current)
+	return change <= s.Tolerance
 }
 
 func (s *Spec) TargetKey() string {
 	return s.Target.Key()
 }
 
-func (s *Spec) ResourceScaler(rName v1.ResourceName) float64 {
-	// TODO: Add ability to scale on these components as well:
-	// corev1.ResourceEphemeralStorage
-	// corev1.ResourceHugePagesPrefix
-	switch rName {
-	case v1.ResourceCPU:
+// ResourceScaler is the amount of a resource which warrants one replica, zero
+// when the check doesn't scale on the resource
+func (s *Spec) ResourceScaler(rName v1.ResourceName) resource.Quantity {
+	switch {
+	case rName == v1.ResourceCPU && !s.CPUPerReplica.IsZero():
 		return s.CPUPerReplica
-	case v1.ResourceMemory:
+	case rName == v1.ResourceMemory && !s.MemoryPerReplica.IsZero():
 		return s.MemoryPerReplica
 	}
-	return 0.0
+	return s.PerReplica[rName]
+}
+
+// Resources lists every resource the check scales on, in a stable order
+func (s *Spec) Resources() []v1.ResourceName {
+	var names []v1.ResourceName
+	for _, rName := range SupportedResources() {
+		if q := s.ResourceScaler(rName); !q.IsZero() {
+			names = append(names, rName)
+		}
+	}
+
+	var extra []v1.ResourceName
+	for rName, q := range s.PerReplica {
+		if !q.IsZero() && !containsResource(names, rName) {
+			extra = append(extra, rName)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i] < extra[j] })
+
+	return append(names, extra...)
 }
@@ -3,13 +3,17 @@ package check_test
 import (
 	"bytes"
 	"encoding/json"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/ryanmt/cluster-resource-autoscaler/check"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func GiveMeAConfigSpecFile() string {
@@ -22,7 +26,7 @@ func GiveMeAConfigSpecFile() string {
 	}
 
 	specs := []check.Spec{
-		{Name: "cpu", CPUPerReplica: 12, Target: fakeTarget},
+		{Name: "cpu", CPUPerReplica: resource.MustParse("12"), Target: fakeTarget},
 	}
 
 	b, _ := json.Marshal(specs)
@@ -42,7 +46,7 @@ func GiveMeATarget() check.ScalingTarget {
 }
 
 func GiveMeASpec() check.Spec {
-	return check.Spec{Name: "cpu", CPUPerReplica: 12, MemoryPerReplica: 12, Target: GiveMeATarget()}
+	return check.Spec{Name: "cpu", CPUPerReplica: resource.MustParse("12"), MemoryPerReplica: resource.MustParse("12"), Target: GiveMeATarget()}
 }
 
 func TestSpec_ResourceScaler(t *testing.T) {
@@ -56,20 +60,112 @@ func TestSpec_ResourceScaler(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			rCPU := check.SupportedResources()[0]
 			rMem := check.SupportedResources()[1]
-			if tt.specification.ResourceScaler(rCPU) != tt.specification.CPUPerReplica {
+			if got := tt.specification.ResourceScaler(rCPU); got.Cmp(tt.specification.CPUPerReplica) != 0 {
 				t.Errorf("check.Spec.ResourceScaler() = %v, want %v", tt.specification.ResourceScaler(rCPU), tt.specification.CPUPerReplica)
 			}
-			if tt.specification.ResourceScaler(rMem) != tt.specification.MemoryPerReplica {
+			if got := tt.specification.ResourceScaler(rMem); got.Cmp(tt.specification.MemoryPerReplica) != 0 {
 				t.Errorf("check.Spec.ResourceScaler() = %v, want %v", tt.specification.ResourceScaler(rMem), tt.specification.MemoryPerReplica)
 			}
 		})
 	}
 
 	// Test the edge case clause
-	var nullValue float64
 	spec := tests[0].specification
-	if spec.ResourceScaler(v1.ResourceHugePagesPrefix) != nullValue {
-		t.Errorf("check.Spec.ResourceScaler() should default to 0.0; got %v", spec.ResourceScaler(v1.ResourceHugePagesPrefix))
+	if got := spec.ResourceScaler(v1.ResourceHugePagesPrefix); !got.IsZero() {
+		t.Errorf("check.Spec.ResourceScaler() should default to zero; got %v", got.String())
+	}
+}
+
+func TestSpec_Resources(t *testing.T) {
+	spec := check.Spec{
+		MemoryPerReplica: resource.MustParse("100Gi"),
+		PerReplica: map[v1.ResourceName]resource.Quantity{
+			"example.com/fpga":          resource.MustParse("1"),
+			v1.ResourceCPU:              resource.MustParse("16"),
+			v1.ResourceEphemeralStorage: resource.MustParse("10Gi"),
+			v1.ResourcePods:             resource.MustParse("0"),
+		},
+	}
+	want := []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourceEphemeralStorage, "example.com/fpga"}
+	if got := spec.Resources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("check.Spec.Resources() = %v, want %v", got, want)
+	}
+	if got := spec.ResourceScaler(v1.ResourceCPU); got.Cmp(resource.MustParse("16")) != 0 {
+		t.Errorf("check.Spec.ResourceScaler() should fall back to PerReplica; got %v", got.String())
+	}
+}
+
+func TestSpec_Inputs(t *testing.T) {
+	spec := check.Spec{
+		CPUPerReplica:             resource.MustParse("16"),
+		NodesPerReplica:           resource.MustParse("10"),
+		SchedulablePodsPerReplica: resource.MustParse("500"),
+		Weights:                   map[v1.ResourceName]float64{check.InputNodes: 2},
+	}
+	want := []v1.ResourceName{v1.ResourceCPU, check.InputNodes, check.InputSchedulablePods}
+	if got := spec.Inputs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("check.Spec.Inputs() = %v, want %v", got, want)
+	}
+	if got := spec.Weight(check.InputNodes); got != 2 {
+		t.Errorf("check.Spec.Weight(nodes) = %v, want 2", got)
+	}
+	if got := spec.Weight(v1.ResourceCPU); got != 1 {
+		t.Errorf("check.Spec.Weight(cpu) = %v, want the default of 1", got)
+	}
+}
+
+func TestSpec_Clamp(t *testing.T) {
+	three, ten := int32(3), int32(10)
+	zero := int32(0)
+	tests := []struct {
+		name      string
+		min, max  *int32
+		replicas  int64
+		want      int32
+		wantBound check.Bound
+	}{
+		{"within bounds", &three, &ten, 5, 5, check.BoundNone},
+		{"raised to min", &three, &ten, 1, 3, check.BoundMin},
+		{"lowered to max", &three, &ten, 80, 10, check.BoundMax},
+		{"on the bound", &three, &ten, 10, 10, check.BoundNone},
+		{"default min", nil, nil, 0, check.DefaultMinReplicas, check.BoundMin},
+		{"scale to zero allowed", &zero, nil, 0, 0, check.BoundNone},
+		{"larger than int32", nil, nil, math.MaxInt64, math.MaxInt32, check.BoundMax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.MinReplicas, spec.MaxReplicas = tt.min, tt.max
+			got, bound := spec.Clamp(tt.replicas)
+			if got != tt.want || bound != tt.wantBound {
+				t.Errorf("check.Spec.Clamp() = %v, %q, want %v, %q", got, bound, tt.want, tt.wantBound)
+			}
+		})
+	}
+}
+
+func TestSpec_Tolerates(t *testing.T) {
+	tests := []struct {
+		name             string
+		tolerance        float64
+		current, desired int32
+		want             bool
+	}{
+		{"no change", 0, 10, 10, true},
+		{"no tolerance", 0, 10, 11, false},
+		{"within tolerance up", 0.1, 10, 11, true},
+		{"within tolerance down", 0.1, 10, 9, true},
+		{"beyond tolerance", 0.1, 10, 12, false},
+		{"from zero", 0.5, 0, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.Tolerance = tt.tolerance
+			if got := spec.Tolerates(tt.current, tt.desired); got != tt.want {
+				t.Errorf("check.Spec.Tolerates(%d, %d) = %v, want %v", tt.current, tt.desired, got, tt.want)
+			}
+		})
 	}
 }
 
@@ -95,7 +191,7 @@ func TestFromReader(t *testing.T) {
 				t.Errorf("FromReader() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !reflect.DeepEqual(got, tt.expected) {
+			if !equality.Semantic.DeepEqual(got, tt.expected) {
 				t.Errorf("FromReader() = %v, want %v", got, tt.expected)
 			}
 		})
@@ -104,23 +200,30 @@ func TestFromReader(t *testing.T) {
 
 func TestScalingTarget_Key(t *testing.T) {
 	type fields struct {
-		Name      string
-		Namespace string
-		Kind      string
+		Name       string
+		Namespace  string
+		Kind       string
+		APIVersion string
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		want   string
 	}{
-		// TODO: Add test cases.
+		{"deployment", fields{"nginx", "default", "deployment", ""}, "deployment->default/nginx"},
+		{"short name", fields{"nginx", "default", "deploy", ""}, "deployment->default/nginx"},
+		{"apps kind", fields{"nginx", "default", "Deployment", "apps/v1"}, "deployment->default/nginx"},
+		{"statefulset", fields{"redis", "default", "sts", ""}, "statefulset->default/redis"},
+		{"custom resource", fields{"web", "default", "Rollout", "argoproj.io/v1alpha1"}, "rollout.argoproj.io->default/web"},
+		{"core group", fields{"web", "default", "ReplicationController", "v1"}, "replicationcontroller->default/web"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &check.ScalingTarget{
-				Name:      tt.fields.Name,
-				Namespace: tt.fields.Namespace,
-				Kind:      tt.fields.Kind,
+				Name:       tt.fields.Name,
+				Namespace:  tt.fields.Namespace,
+				Kind:       tt.fields.Kind,
+				APIVersion: tt.fields.APIVersion,
 			}
 			if got := s.Key(); got != tt.want {
 				t.Errorf("ScalingTarget.Key() = %v, want %v", got, tt.want)
@@ -131,8 +234,8 @@ func TestScalingTarget_Key(t *testing.T) {
 
 func TestSpec_TargetKey(t *testing.T) {
 	type fields struct {
-		CPUPerReplica    float64
-		MemoryPerReplica float64
+		CPUPerReplica    resource.Quantity
+		MemoryPerReplica resource.Quantity
 		Name             string
 		Target           check.ScalingTarget
 	}
@@ -157,3 +260,126 @@ func TestSpec_TargetKey(t *testing.T) {
 		})
 	}
 }
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const jsonConfig = `[
+  {"CPUPerReplica": 16, "Name": "cpu", "Target": {"Kind": "deployment", "Name": "json", "Namespace": "default"}}
+]`
+
+const yamlConfig = `---
+cpuPerReplica: 16
+name: cpu
+target:
+  kind: deployment
+  name: yaml-one
+  namespace: default
+---
+- memoryPerReplica: 100e9
+  name: memory
+  target:
+    kind: statefulset
+    name: yaml-two
+    namespace: default
+---
+`
+
+func TestFromFile_Formats(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		path      string
+		wantNames []string
+	}{
+		{"json by extension", writeConfig(t, dir, "config.json", jsonConfig), []string{"json"}},
+		{"yaml by extension", writeConfig(t, dir, "config.yaml", yamlConfig), []string{"yaml-one", "yaml-two"}},
+		{"yml by extension", writeConfig(t, dir, "config.yml", yamlConfig), []string{"yaml-one", "yaml-two"}},
+		{"json by content", writeConfig(t, dir, "json-config", "\n  "+jsonConfig), []string{"json"}},
+		{"yaml by content", writeConfig(t, dir, "yaml-config", yamlConfig), []string{"yaml-one", "yaml-two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := check.FromFile(tt.path)
+			if err != nil {
+				t.Fatalf("FromFile() error = %v", err)
+			}
+			var names []string
+			for _, s := range got {
+				names = append(names, s.Target.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("FromFile() targets = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestFromYAMLReader_Values(t *testing.T) {
+	got, err := check.FromYAMLReader(strings.NewReader(yamlConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []check.Spec{
+		{Name: "cpu", CPUPerReplica: resource.MustParse("16"), Target: check.ScalingTarget{Kind: "deployment", Name: "yaml-one", Namespace: "default"}},
+		{Name: "memory", MemoryPerReplica: resource.MustParse("100e9"), Target: check.ScalingTarget{Kind: "statefulset", Name: "yaml-two", Namespace: "default"}},
+	}
+	if !equality.Semantic.DeepEqual(got, expected) {
+		t.Errorf("FromYAMLReader() = %v, want %v", got, expected)
+	}
+
+	if _, err := check.FromYAMLReader(strings.NewReader("- cpuPerReplica: [16\n")); err == nil {
+		t.Errorf("FromYAMLReader() should fail on malformed YAML")
+	}
+}
+
+func TestFromFile_Directory(t *testing.T) {
+	targetNames := func(specs []check.Spec) []string {
+		var names []string
+		for _, s := range specs {
+			names = append(names, s.Target.Name)
+		}
+		return names
+	}
+
+	dir := t.TempDir()
+	writeConfig(t, dir, "a.json", jsonConfig)
+	writeConfig(t, dir, "b.yaml", yamlConfig)
+	writeConfig(t, dir, "notes.txt", "not a config")
+	writeConfig(t, dir, ".hidden.json", "not a config either")
+
+	got, err := check.FromFile(dir)
+	if err != nil {
+		t.Fatalf("FromFile() error = %v", err)
+	}
+	want := []string{"json", "yaml-one", "yaml-two"}
+	if names := targetNames(got); !reflect.DeepEqual(names, want) {
+		t.Errorf("FromFile() targets = %v, want %v", names, want)
+	}
+
+	writeConfig(t, dir, "d.json", "[{")
+	if _, err := check.FromFile(dir); err == nil || !strings.Contains(err.Error(), "d.json") {
+		t.Errorf("FromFile() should name the broken file, got %v", err)
+	}
+
+	// A ConfigMap volume is read through its `..data` symlink
+	projected := t.TempDir()
+	projectConfigMap(t, projected, "1", map[string]string{
+		"a.json": jsonConfig,
+		"c.yml":  "- {cpuPerReplica: 1, target: {kind: deployment, name: linked, namespace: default}}",
+	})
+	got, err = check.FromFile(projected)
+	if err != nil {
+		t.Fatalf("FromFile() error = %v", err)
+	}
+	want = []string{"json", "linked"}
+	if names := targetNames(got); !reflect.DeepEqual(names, want) {
+		t.Errorf("FromFile() targets = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,178 @@
+package check
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DeepCopyInto copies the receiver into out.  Spec is embedded in the
+// ScalingPolicy CRD, so it has to satisfy the deepcopy contract used by
+// client-go.
+func (in *Spec) DeepCopyInto(out *Spec) {
+	*out = *in
+	out.CPUPerReplica = in.CPUPerReplica.DeepCopy()
+	out.MemoryPerReplica = in.MemoryPerReplica.DeepCopy()
+	out.NodesPerReplica = in.NodesPerReplica.DeepCopy()
+	out.SchedulablePodsPerReplica = in.SchedulablePodsPerReplica.DeepCopy()
+	if in.PerReplica != nil {
+		in, out := &in.PerReplica, &out.PerReplica
+		*out = make(map[v1.ResourceName]resource.Quantity, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Prediction != nil {
+		out.Prediction = new(Prediction)
+		*out.Prediction = *in.Prediction
+	}
+	if in.Ladder != nil {
+		out.Ladder = in.Ladder.DeepCopy()
+	}
+	if in.Weights != nil {
+		in, out := &in.Weights, &out.Weights
+		*out = make(map[v1.ResourceName]float64, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Rounding != nil {
+		out.Rounding = new(Rounding)
+		*out.Rounding = *in.Rounding
+	}
+	in.Nodes.DeepCopyInto(&out.Nodes)
+	if in.ScaleUp != nil {
+		out.ScaleUp = in.ScaleUp.DeepCopy()
+	}
+	if in.ScaleDown != nil {
+		out.ScaleDown = in.ScaleDown.DeepCopy()
+	}
+	if in.Schedules != nil {
+		out.Schedules = make([]Schedule, len(in.Schedules))
+		for i := range in.Schedules {
+			in.Schedules[i].DeepCopyInto(&out.Schedules[i])
+		}
+	}
+	if in.HPA != nil {
+		out.HPA = new(HPAFloor)
+		*out.HPA = *in.HPA
+	}
+	if in.MinReplicas != nil {
+		out.MinReplicas = new(int32)
+		*out.MinReplicas = *in.MinReplicas
+	}
+	if in.MaxReplicas != nil {
+		out.MaxReplicas = new(int32)
+		*out.MaxReplicas = *in.MaxReplicas
+	}
+}
+
+// DeepCopy creates a new Spec which is a deep copy of the receiver
+func (in *Spec) DeepCopy() *Spec {
+	if in == nil {
+		return nil
+	}
+	out := new(Spec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *ScalingTarget) DeepCopyInto(out *ScalingTarget) {
+	*out = *in
+}
+
+// DeepCopy creates a new ScalingTarget which is a deep copy of the receiver
+func (in *ScalingTarget) DeepCopy() *ScalingTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(ScalingTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *NodeFilter) DeepCopyInto(out *NodeFilter) {
+	*out = *in
+	if in.IncludeTaints != nil {
+		out.IncludeTaints = make([]TaintRule, len(in.IncludeTaints))
+		copy(out.IncludeTaints, in.IncludeTaints)
+	}
+	if in.ExcludeTaints != nil {
+		out.ExcludeTaints = make([]TaintRule, len(in.ExcludeTaints))
+		copy(out.ExcludeTaints, in.ExcludeTaints)
+	}
+}
+
+// DeepCopy creates a new NodeFilter which is a deep copy of the receiver
+func (in *NodeFilter) DeepCopy() *NodeFilter {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeFilter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *Ladder) DeepCopyInto(out *Ladder) {
+	*out = *in
+	if in.Steps != nil {
+		out.Steps = make([]Step, len(in.Steps))
+		for i := range in.Steps {
+			out.Steps[i] = Step{Threshold: in.Steps[i].Threshold.DeepCopy(), Replicas: in.Steps[i].Replicas}
+		}
+	}
+}
+
+// DeepCopy creates a new Ladder which is a deep copy of the receiver
+func (in *Ladder) DeepCopy() *Ladder {
+	if in == nil {
+		return nil
+	}
+	out := new(Ladder)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *RateRules) DeepCopyInto(out *RateRules) {
+	*out = *in
+	if in.Policies != nil {
+		out.Policies = make([]RatePolicy, len(in.Policies))
+		copy(out.Policies, in.Policies)
+	}
+}
+
+// DeepCopy creates a new RateRules which is a deep copy of the receiver
+func (in *RateRules) DeepCopy() *RateRules {
+	if in == nil {
+		return nil
+	}
+	out := new(RateRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *Schedule) DeepCopyInto(out *Schedule) {
+	*out = *in
+	if in.MinReplicas != nil {
+		out.MinReplicas = new(int32)
+		*out.MinReplicas = *in.MinReplicas
+	}
+	if in.MaxReplicas != nil {
+		out.MaxReplicas = new(int32)
+		*out.MaxReplicas = *in.MaxReplicas
+	}
+}
+
+// DeepCopy creates a new Schedule which is a deep copy of the receiver
+func (in *Schedule) DeepCopy() *Schedule {
+	if in == nil {
+		return nil
+	}
+	out := new(Schedule)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,69 @@
+package check
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Eligibility decides which of the nodes passing the filter count towards
+// capacity and usage at all.  The zero value drops cordoned nodes, NotReady
+// nodes and nodes being deleted, e.g. while a node pool is drained.
+type Eligibility struct {
+	// IncludeUnschedulable keeps cordoned nodes
+	IncludeUnschedulable bool `json:"includeUnschedulable,omitempty"`
+	// IncludeDeleting keeps nodes with a deletion timestamp
+	IncludeDeleting bool `json:"includeDeleting,omitempty"`
+	// NotReadyGracePeriod keeps nodes which have been NotReady for less than
+	// it, e.g. "5m", so a node briefly losing contact still counts
+	NotReadyGracePeriod metav1.Duration `json:"notReadyGracePeriod,omitempty"`
+	// WarmUp drops nodes created less than it ago, e.g. "2m"
+	WarmUp metav1.Duration `json:"warmUp,omitempty"`
+}
+
+// Reasons a node isn't eligible
+const (
+	IneligibleUnschedulable = "unschedulable"
+	IneligibleDeleting      = "deleting"
+	IneligibleNotReady      = "notReady"
+	IneligibleWarmingUp     = "warmingUp"
+)
+
+// Ineligible is why the node doesn't count at now, empty when it does
+func (e *Eligibility) Ineligible(node *v1.Node, now time.Time) string {
+	switch {
+	case node.Spec.Unschedulable && !e.IncludeUnschedulable:
+		return IneligibleUnschedulable
+	case node.DeletionTimestamp != nil && !e.IncludeDeleting:
+		return IneligibleDeleting
+	case now.Sub(node.CreationTimestamp.Time) < e.WarmUp.Duration:
+		return IneligibleWarmingUp
+	}
+
+	if since, ready := readySince(node); !ready && now.Sub(since) >= e.NotReadyGracePeriod.Duration {
+		return IneligibleNotReady
+	}
+	return ""
+}
+
+// readySince reports whether the node is Ready and since when it has been in
+// that state.  A node without a Ready condition has never been ready.
+func readySince(node *v1.Node) (time.Time, bool) {
+	for _, c := range node.Status.Conditions {
+		if c.Type == v1.NodeReady {
+			return c.LastTransitionTime.Time, c.Status == v1.ConditionTrue
+		}
+	}
+	return node.CreationTimestamp.Time, false
+}
+
+// validateEligibility checks the eligibility rules of a check
+func validateEligibility(add addProblem, e *Eligibility) {
+	if e.NotReadyGracePeriod.Duration < 0 {
+		add("Eligibility.NotReadyGracePeriod", "must not be negative, got %v", e.NotReadyGracePeriod.Duration)
+	}
+	if e.WarmUp.Duration < 0 {
+		add("Eligibility.WarmUp", "must not be negative, got %v", e.WarmUp.Duration)
+	}
+}
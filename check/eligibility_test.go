@@ -0,0 +1,73 @@
+package check_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GiveMeANodeAged returns a node created age before now, Ready or not since
+// transitioned before now
+func GiveMeANodeAged(now time.Time, age time.Duration, ready bool, transitioned time.Duration) *v1.Node {
+	node := GiveMeANode(nil)
+	node.CreationTimestamp = metav1.NewTime(now.Add(-age))
+	status := v1.ConditionTrue
+	if !ready {
+		status = v1.ConditionFalse
+	}
+	node.Status.Conditions = []v1.NodeCondition{{
+		Type:               v1.NodeReady,
+		Status:             status,
+		LastTransitionTime: metav1.NewTime(now.Add(-transitioned)),
+	}}
+	return node
+}
+
+func TestEligibility_Ineligible(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	minutes := func(m int) metav1.Duration { return metav1.Duration{Duration: time.Duration(m) * time.Minute} }
+
+	ready := GiveMeANodeAged(now, time.Hour, true, time.Hour)
+	cordoned := GiveMeANodeAged(now, time.Hour, true, time.Hour)
+	cordoned.Spec.Unschedulable = true
+	deleting := GiveMeANodeAged(now, time.Hour, true, time.Hour)
+	deleted := metav1.NewTime(now.Add(-time.Minute))
+	deleting.DeletionTimestamp = &deleted
+	notReady := GiveMeANodeAged(now, time.Hour, false, 3*time.Minute)
+	unknown := GiveMeANodeAged(now, time.Hour, true, time.Hour)
+	unknown.Status.Conditions[0].Status = v1.ConditionUnknown
+	young := GiveMeANodeAged(now, time.Minute, true, time.Minute)
+	noConditions := GiveMeANode(nil)
+	noConditions.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name  string
+		rules check.Eligibility
+		node  *v1.Node
+		want  string
+	}{
+		{"ready", check.Eligibility{}, ready, ""},
+		{"cordoned", check.Eligibility{}, cordoned, check.IneligibleUnschedulable},
+		{"cordoned included", check.Eligibility{IncludeUnschedulable: true}, cordoned, ""},
+		{"deleting", check.Eligibility{}, deleting, check.IneligibleDeleting},
+		{"deleting included", check.Eligibility{IncludeDeleting: true}, deleting, ""},
+		{"not ready", check.Eligibility{}, notReady, check.IneligibleNotReady},
+		{"not ready within grace period", check.Eligibility{NotReadyGracePeriod: minutes(5)}, notReady, ""},
+		{"not ready beyond grace period", check.Eligibility{NotReadyGracePeriod: minutes(2)}, notReady, check.IneligibleNotReady},
+		{"unknown readiness", check.Eligibility{}, unknown, check.IneligibleNotReady},
+		{"no ready condition", check.Eligibility{NotReadyGracePeriod: minutes(5)}, noConditions, check.IneligibleNotReady},
+		{"young without warm up", check.Eligibility{}, young, ""},
+		{"warming up", check.Eligibility{WarmUp: minutes(2)}, young, check.IneligibleWarmingUp},
+		{"warmed up", check.Eligibility{WarmUp: minutes(2)}, ready, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Ineligible(tt.node, now); got != tt.want {
+				t.Errorf("check.Eligibility.Ineligible() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
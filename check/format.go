@@ -0,0 +1,229 @@
+package check
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Format of a configuration file
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// formatForExtension returns the format implied by a file name, if any
+func formatForExtension(path string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, true
+	case ".yaml", ".yml":
+		return FormatYAML, true
+	}
+	return "", false
+}
+
+// sniffFormat guesses the format from the first non-whitespace byte.  JSON
+// config is always an array or an object; anything else is treated as YAML.
+func sniffFormat(r *bufio.Reader) Format {
+	for i := 1; ; i++ {
+		b, err := r.Peek(i)
+		if err != nil {
+			return FormatYAML
+		}
+		switch c := b[i-1]; c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[', '{':
+			return FormatJSON
+		default:
+			return FormatYAML
+		}
+	}
+}
+
+// FromYAMLReader decodes a YAML stream of one or more `---` separated
+// documents.  Each document is either a list of specs or a single spec.
+func FromYAMLReader(r io.Reader) ([]Spec, error) {
+	return finish(decodeYAML(r, ""))
+}
+
+func decodeYAML(r io.Reader, file string) ([]entry, []FieldError) {
+	var entries []entry
+	var problems []FieldError
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for doc := 1; ; doc++ {
+		docPosition := Position{File: file, Document: doc}
+
+		raw, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return entries, append(problems, FieldError{Position: docPosition, Message: err.Error()})
+		}
+
+		js, err := yaml.YAMLToJSON(raw)
+		if err != nil {
+			problems = append(problems, FieldError{Position: docPosition, Message: err.Error()})
+			continue
+		}
+		js = bytes.TrimSpace(js)
+		if len(js) == 0 || bytes.Equal(js, []byte("null")) {
+			// Empty document, e.g. a leading or trailing `---`
+			continue
+		}
+
+		items := []json.RawMessage{js}
+		if js[0] == '[' {
+			if err := json.Unmarshal(js, &items); err != nil {
+				problems = append(problems, FieldError{Position: docPosition, Message: err.Error()})
+				continue
+			}
+		}
+
+		for i, item := range items {
+			pos := docPosition
+			pos.Index = i
+			// Offsets into the converted JSON mean nothing to the author of the YAML
+			spec, specProblems := decodeSpec(item, func(int64) Position { return pos })
+			problems = append(problems, specProblems...)
+			entries = append(entries, entry{Spec: spec, Position: pos})
+		}
+	}
+
+	return entries, problems
+}
+
+// decodeFormatted decodes r as the given format, sniffing the content when no
+// format is known
+func decodeFormatted(r io.Reader, format Format, file string) ([]entry, []FieldError) {
+	br := bufio.NewReader(r)
+	if format == "" {
+		format = sniffFormat(br)
+	}
+
+	if format == FormatJSON {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, []FieldError{{Position: Position{File: file}, Message: err.Error()}}
+		}
+		return decodeJSON(data, file)
+	}
+	return decodeYAML(br, file)
+}
+
+// FromDirectory reads every .json, .yaml and .yml file in dir, in name order.
+// Kubelet's ConfigMap bookkeeping entries (`..data` and friends) are skipped.
+// Duplicate targets are detected across files.
+func FromDirectory(dir string) ([]Spec, error) {
+	return fromDirectory(dir, nil)
+}
+
+// fromPath reads a file or directory, feeding every byte read into h when it
+// is not nil
+func fromPath(path string, h hash.Hash) ([]Spec, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return fromDirectory(path, h)
+	}
+
+	return fromPlainFile(path, h)
+}
+
+// configMapDataDir is the symlink kubelet swaps atomically when a mounted
+// ConfigMap changes
+const configMapDataDir = "..data"
+
+func fromDirectory(dir string, h hash.Hash) ([]Spec, error) {
+	// Read a ConfigMap volume through the resolved data directory, so that a
+	// symlink swap part way through can't mix two versions of the config
+	if resolved, err := filepath.EvalSymlinks(filepath.Join(dir, configMapDataDir)); err == nil {
+		dir = resolved
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if _, ok := formatForExtension(e.Name()); !ok {
+			continue
+		}
+		// Follow symlinks, which is how ConfigMap keys are projected
+		info, err := os.Stat(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().IsRegular() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var specEntries []entry
+	var problems []FieldError
+	for _, name := range names {
+		fileEntries, fileProblems, err := decodeFile(filepath.Join(dir, name), name, h)
+		if err != nil {
+			return nil, err
+		}
+		specEntries = append(specEntries, fileEntries...)
+		problems = append(problems, fileProblems...)
+	}
+
+	return finish(specEntries, problems)
+}
+
+func fromPlainFile(path string, h hash.Hash) ([]Spec, error) {
+	entries, problems, err := decodeFile(path, filepath.Base(path), h)
+	if err != nil {
+		return nil, err
+	}
+
+	return finish(entries, problems)
+}
+
+// decodeFile decodes a single file, naming it in positions as name
+func decodeFile(path, name string, h hash.Hash) ([]entry, []FieldError, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if h != nil {
+		fmt.Fprintf(h, "%s\x00", name)
+		r = io.TeeReader(file, h)
+	}
+
+	format, _ := formatForExtension(path)
+	entries, problems := decodeFormatted(r, format, name)
+	for i := range entries {
+		entries[i].Spec.Source = name
+	}
+	return entries, problems, nil
+}
@@ -0,0 +1,66 @@
+package check
+
+// Group is every check configured for a single target.  A group is reconciled
+// as one unit, so the checks never fight over the replica count.
+type Group struct {
+	Target ScalingTarget
+	Checks []Spec
+}
+
+// Key is the key shared by the target of every check in the group
+func (g *Group) Key() string {
+	return g.Target.Key()
+}
+
+// Names lists the checks contributing to the group, in configuration order
+func (g *Group) Names() []string {
+	names := make([]string, len(g.Checks))
+	for i, c := range g.Checks {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// GroupByTarget collects specs into one Group per target, keeping both the
+// targets and their checks in the order they were first seen
+func GroupByTarget(specs []Spec) []Group {
+	var groups []Group
+	index := make(map[string]int)
+
+	for _, s := range specs {
+		i, ok := index[s.TargetKey()]
+		if !ok {
+			i = len(groups)
+			index[s.TargetKey()] = i
+			groups = append(groups, Group{Target: s.Target})
+		}
+		groups[i].Checks = append(groups[i].Checks, s)
+	}
+
+	return groups
+}
+
+// WithPrecedence combines specs from several sources, highest precedence
+// first.  A target configured by any source ignores every check for it from the
+// sources after it, so e.g. an explicit config always overrides annotations.
+func WithPrecedence(sources ...[]Spec) []Spec {
+	var specList []Spec
+	claimed := make(map[string]bool)
+
+	for _, source := range sources {
+		sourceTargets := make(map[string]bool)
+		for _, s := range source {
+			if claimed[s.TargetKey()] {
+				logger.V(1).Info("Target already configured by a higher precedence source, ignoring", "deploymentKey", s.TargetKey(), "checkSpec", s.Name, "source", s.Source)
+				continue
+			}
+			sourceTargets[s.TargetKey()] = true
+			specList = append(specList, s)
+		}
+		for key := range sourceTargets {
+			claimed[key] = true
+		}
+	}
+
+	return specList
+}
@@ -0,0 +1,55 @@
+package check_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGroupByTarget(t *testing.T) {
+	nginx := check.ScalingTarget{Name: "nginx", Namespace: "default", Kind: "deployment"}
+	redis := check.ScalingTarget{Name: "redis", Namespace: "default", Kind: "statefulset"}
+
+	specs := []check.Spec{
+		{Name: "cpu", CPUPerReplica: resource.MustParse("16"), Target: nginx},
+		{Name: "memory", MemoryPerReplica: resource.MustParse("1e9"), Target: redis},
+		{Name: "memory", MemoryPerReplica: resource.MustParse("100e9"), Target: nginx},
+	}
+
+	groups := check.GroupByTarget(specs)
+	if len(groups) != 2 {
+		t.Fatalf("GroupByTarget() returned %d groups, want 2", len(groups))
+	}
+	if groups[0].Key() != nginx.Key() || groups[1].Key() != redis.Key() {
+		t.Errorf("GroupByTarget() should keep targets in first seen order, got %v, %v", groups[0].Key(), groups[1].Key())
+	}
+	if got := groups[0].Names(); !reflect.DeepEqual(got, []string{"cpu", "memory"}) {
+		t.Errorf("Group.Names() = %v", got)
+	}
+	if !equality.Semantic.DeepEqual(groups[0].Checks, []check.Spec{specs[0], specs[2]}) {
+		t.Errorf("GroupByTarget() checks = %v", groups[0].Checks)
+	}
+}
+
+func TestWithPrecedence(t *testing.T) {
+	nginx := check.ScalingTarget{Name: "nginx", Namespace: "default", Kind: "deployment"}
+	redis := check.ScalingTarget{Name: "redis", Namespace: "default", Kind: "statefulset"}
+
+	explicit := []check.Spec{
+		{Name: "cpu", CPUPerReplica: resource.MustParse("16"), Target: nginx, Source: "config.json"},
+		{Name: "memory", MemoryPerReplica: resource.MustParse("1e9"), Target: nginx, Source: "config.json"},
+	}
+	annotated := []check.Spec{
+		{Name: "annotations", CPUPerReplica: resource.MustParse("4"), Target: nginx, Source: "annotations"},
+		{Name: "annotations", CPUPerReplica: resource.MustParse("4"), Target: redis, Source: "annotations"},
+	}
+
+	got := check.WithPrecedence(explicit, annotated)
+	want := []check.Spec{explicit[0], explicit[1], annotated[1]}
+	if !equality.Semantic.DeepEqual(got, want) {
+		t.Errorf("WithPrecedence() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,49 @@
+package check
+
+import (
+	"math"
+)
+
+// HPAFloor manages the minReplicas of the target's HorizontalPodAutoscaler
+// instead of scaling the target, so the HPA keeps scaling above a floor set by
+// cluster capacity
+type HPAFloor struct {
+	// MaxReplicasMultiplier also sets maxReplicas to the recommendation times
+	// it, e.g. 4, leaving maxReplicas alone when unset
+	MaxReplicasMultiplier float64 `json:"maxReplicasMultiplier,omitempty"`
+}
+
+// MaxReplicas is the maxReplicas for a floor of minReplicas, ok is false when
+// maxReplicas is left alone
+func (h *HPAFloor) MaxReplicas(minReplicas int32) (max int32, ok bool) {
+	if h.MaxReplicasMultiplier == 0 {
+		return 0, false
+	}
+	max = saturate(math.Ceil(float64(minReplicas) * h.MaxReplicasMultiplier))
+	if max < minReplicas {
+		max = minReplicas
+	}
+	return max, true
+}
+
+// HPA is how the group manages the HPA of its target, from the first check
+// setting HPA.  It is nil when the group scales its target directly.
+func (g *Group) HPA() *HPAFloor {
+	for i := range g.Checks {
+		if g.Checks[i].HPA != nil {
+			return g.Checks[i].HPA
+		}
+	}
+	return nil
+}
+
+// validateHPA checks the HPA management of a check, if any
+func validateHPA(add addProblem, h *HPAFloor) {
+	if h == nil {
+		return
+	}
+
+	if m := h.MaxReplicasMultiplier; math.IsNaN(m) || math.IsInf(m, 0) || (m != 0 && m < 1) {
+		add("HPA.MaxReplicasMultiplier", "must be at least 1 when set, got %v", m)
+	}
+}
@@ -0,0 +1,76 @@
+package check_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+)
+
+func TestHPAFloor_MaxReplicas(t *testing.T) {
+	tests := []struct {
+		name       string
+		multiplier float64
+		min        int32
+		want       int32
+		ok         bool
+	}{
+		{"left alone", 0, 5, 0, false},
+		{"multiplied", 4, 5, 20, true},
+		{"rounded up", 1.5, 5, 8, true},
+		{"saturated", 4, math.MaxInt32, math.MaxInt32, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			floor := check.HPAFloor{MaxReplicasMultiplier: tt.multiplier}
+			got, ok := floor.MaxReplicas(tt.min)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("check.HPAFloor.MaxReplicas(%d) = %v, %v, want %v, %v", tt.min, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGroup_HPA(t *testing.T) {
+	direct := GiveMeASpec()
+	managed := GiveMeASpec()
+	managed.Name = "floor"
+	managed.HPA = &check.HPAFloor{MaxReplicasMultiplier: 3}
+
+	if got := check.GroupByTarget([]check.Spec{direct})[0].HPA(); got != nil {
+		t.Errorf("check.Group.HPA() = %v, want nil without a check setting it", got)
+	}
+	if got := check.GroupByTarget([]check.Spec{direct, managed})[0].HPA(); got == nil || got.MaxReplicasMultiplier != 3 {
+		t.Errorf("check.Group.HPA() = %v, want the floor of the managing check", got)
+	}
+}
+
+func TestValidate_HPA(t *testing.T) {
+	tests := []struct {
+		name       string
+		multiplier float64
+		wantErr    bool
+	}{
+		{"unset", 0, false},
+		{"at least one", 1, false},
+		{"below one", 0.5, true},
+		{"negative", -2, true},
+		{"not a number", math.NaN(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.HPA = &check.HPAFloor{MaxReplicasMultiplier: tt.multiplier}
+			err := check.Validate([]check.Spec{spec})
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if verr := problemsOf(t, err); len(verr) != 1 || verr[0].Field != "HPA.MaxReplicasMultiplier" {
+				t.Errorf("Validate() = %v, want a problem with HPA.MaxReplicasMultiplier", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package check
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Mode is how a check turns cluster capacity into replicas
+type Mode string
+
+const (
+	// ModeLinear wants one replica per PerReplica amount of capacity
+	ModeLinear Mode = "linear"
+	// ModeLadder picks the replicas of the highest Ladder step reached
+	ModeLadder Mode = "ladder"
+)
+
+// Modes lists every supported mode, the first one being the default
+func Modes() []Mode {
+	return []Mode{ModeLinear, ModeLadder}
+}
+
+// Ladder is a step function from the cluster's total amount of an input to
+// replicas, e.g. 1 replica up to 64 cores, 3 up to 512 cores and 5 beyond
+type Ladder struct {
+	// Input is a resource such as cpu or memory, or InputNodes
+	Input string `json:"input"`
+	// Steps in increasing order of Threshold
+	Steps []Step `json:"steps"`
+}
+
+// Step is reached once the input is at least Threshold
+type Step struct {
+	Threshold resource.Quantity `json:"threshold"`
+	Replicas  int32             `json:"replicas"`
+}
+
+// IsNodes reports whether the ladder counts nodes rather than a resource
+func (l *Ladder) IsNodes() bool {
+	return l.Input == string(InputNodes)
+}
+
+// Resource is the resource the ladder is keyed on, empty for InputNodes
+func (l *Ladder) Resource() v1.ResourceName {
+	if l.IsNodes() {
+		return ""
+	}
+	return v1.ResourceName(l.Input)
+}
+
+// Replicas of the highest step reached by value, zero when even the first
+// step isn't reached
+func (l *Ladder) Replicas(value resource.Quantity) int32 {
+	var replicas int32
+	for _, step := range l.Steps {
+		if value.Cmp(step.Threshold) < 0 {
+			break
+		}
+		replicas = step.Replicas
+	}
+	return replicas
+}
+
+// validateLadder checks the ladder of a check in ladder mode
+func validateLadder(add addProblem, l *Ladder) {
+	if l == nil {
+		add("Ladder", "must be set in %s mode", ModeLadder)
+		return
+	}
+
+	if l.Input == "" {
+		add("Ladder.Input", "must not be empty")
+	} else if !l.IsNodes() {
+		for _, msg := range validateResourceName(l.Resource()) {
+			add("Ladder.Input", "must be %q or a resource name: %s", InputNodes, msg)
+		}
+	}
+
+	if len(l.Steps) == 0 {
+		add("Ladder.Steps", "must have at least one step")
+	}
+	for i, step := range l.Steps {
+		field := fmt.Sprintf("Ladder.Steps[%d]", i)
+		if step.Threshold.Sign() < 0 {
+			add(field+".Threshold", "must not be negative, got %v", step.Threshold.String())
+		}
+		if i > 0 && step.Threshold.Cmp(l.Steps[i-1].Threshold) <= 0 {
+			add(field+".Threshold", "must be greater than the previous step's %v, got %v", l.Steps[i-1].Threshold.String(), step.Threshold.String())
+		}
+		if step.Replicas < 0 {
+			add(field+".Replicas", "must not be negative, got %d", step.Replicas)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package check_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func GiveMeALadder() *check.Ladder {
+	return &check.Ladder{
+		Input: "cpu",
+		Steps: []check.Step{
+			{Threshold: resource.MustParse("1"), Replicas: 1},
+			{Threshold: resource.MustParse("64"), Replicas: 3},
+			{Threshold: resource.MustParse("512"), Replicas: 5},
+		},
+	}
+}
+
+func TestLadder_Replicas(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int32
+	}{
+		{"500m", 0},
+		{"1", 1},
+		{"63999m", 1},
+		{"64", 3},
+		{"511", 3},
+		{"512", 5},
+		{"100k", 5},
+	}
+	ladder := GiveMeALadder()
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ladder.Replicas(resource.MustParse(tt.value)); got != tt.want {
+				t.Errorf("Ladder.Replicas(%s) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_Ladder(t *testing.T) {
+	ladderSpec := func(modify func(s *check.Spec)) check.Spec {
+		s := check.Spec{Name: "dns", Mode: check.ModeLadder, Ladder: GiveMeALadder(), Target: GiveMeATarget()}
+		modify(&s)
+		return s
+	}
+
+	tests := []struct {
+		name       string
+		spec       check.Spec
+		wantFields []string
+	}{
+		{"valid", ladderSpec(func(s *check.Spec) {}), nil},
+		{"by nodes", ladderSpec(func(s *check.Spec) { s.Ladder.Input = string(check.InputNodes) }), nil},
+		{"missing ladder", ladderSpec(func(s *check.Spec) { s.Ladder = nil }), []string{"Ladder"}},
+		{"ladder in linear mode", ladderSpec(func(s *check.Spec) { s.Mode, s.CPUPerReplica = check.ModeLinear, resource.MustParse("16") }), []string{"Ladder"}},
+		{"per replica in ladder mode", ladderSpec(func(s *check.Spec) { s.CPUPerReplica = resource.MustParse("16") }), []string{""}},
+		{"nodes per replica in ladder mode", ladderSpec(func(s *check.Spec) { s.NodesPerReplica = resource.MustParse("10") }), []string{""}},
+		{"unknown mode", ladderSpec(func(s *check.Spec) { s.Mode = "stairs" }), []string{"Mode"}},
+		{"bad input", ladderSpec(func(s *check.Spec) { s.Ladder.Input = "all the cores" }), []string{"Ladder.Input"}},
+		{"no steps", ladderSpec(func(s *check.Spec) { s.Ladder.Steps = nil }), []string{"Ladder.Steps"}},
+		{"unordered steps", ladderSpec(func(s *check.Spec) { s.Ladder.Steps[2].Threshold = resource.MustParse("64") }), []string{"Ladder.Steps[2].Threshold"}},
+		{"negative replicas", ladderSpec(func(s *check.Spec) { s.Ladder.Steps[0].Replicas = -1 }), []string{"Ladder.Steps[0].Replicas"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := check.Validate([]check.Spec{tt.spec})
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			var fields []string
+			for _, p := range problemsOf(t, err) {
+				fields = append(fields, p.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Validate() problems = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestFromYAMLReader_Ladder(t *testing.T) {
+	config := `
+- name: dns
+  mode: ladder
+  ladder:
+    input: cpu
+    steps:
+      - {threshold: 1, replicas: 1}
+      - {threshold: 64, replicas: 3}
+      - {threshold: 512, replicas: 5}
+  target: {kind: deployment, name: name, namespace: default}
+`
+	got, err := check.FromYAMLReader(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0].Ladder.Steps) != 3 || got[0].Ladder.Replicas(resource.MustParse("100")) != 3 {
+		t.Errorf("FromYAMLReader() = %+v, want the three step ladder", got)
+	}
+
+	_, err = check.FromYAMLReader(strings.NewReader(strings.Replace(config, "replicas: 5", "replicas: 5, extra: 1", 1)))
+	if err == nil || !strings.Contains(err.Error(), "ladder.steps[2].extra") {
+		t.Errorf("FromYAMLReader() should reject unknown ladder keys, got %v", err)
+	}
+}
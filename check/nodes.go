@@ -0,0 +1,86 @@
+package check
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NodeFilter narrows the nodes whose capacity and usage a check scales with,
+// e.g. a single node pool.  The zero value matches every node.
+type NodeFilter struct {
+	// Selector is a label selector, e.g. "pool=batch" or "pool in (batch,spot),!gpu"
+	Selector string `json:"selector,omitempty"`
+	// IncludeTaints keeps only nodes carrying a taint matching each rule
+	IncludeTaints []TaintRule `json:"includeTaints,omitempty"`
+	// ExcludeTaints drops nodes carrying a taint matching any rule
+	ExcludeTaints []TaintRule `json:"excludeTaints,omitempty"`
+	// MatchTarget keeps only nodes the target's pods could be scheduled onto,
+	// going by the node selector, required node affinity and tolerations of
+	// its pod template
+	MatchTarget bool `json:"matchTarget,omitempty"`
+}
+
+// TaintRule matches node taints by key and, when set, by value and effect
+type TaintRule struct {
+	Key    string         `json:"key"`
+	Value  string         `json:"value,omitempty"`
+	Effect v1.TaintEffect `json:"effect,omitempty"`
+}
+
+// SupportedTaintEffects are the effects a TaintRule may name
+func SupportedTaintEffects() []v1.TaintEffect {
+	return []v1.TaintEffect{
+		v1.TaintEffectNoSchedule,
+		v1.TaintEffectPreferNoSchedule,
+		v1.TaintEffectNoExecute,
+	}
+}
+
+// IsZero reports whether the filter matches every node
+func (f *NodeFilter) IsZero() bool {
+	return f.Selector == "" && len(f.IncludeTaints) == 0 && len(f.ExcludeTaints) == 0 && !f.MatchTarget
+}
+
+// LabelSelector parses Selector, matching everything when it is empty
+func (f *NodeFilter) LabelSelector() (labels.Selector, error) {
+	return labels.Parse(f.Selector)
+}
+
+// Matches reports whether the node passes the taint rules and the selector.
+// MatchTarget needs the target's pod template, so it is left to the caller.
+// An unparseable selector matches nothing, although validation rejects those
+// before they get here.
+func (f *NodeFilter) Matches(node *v1.Node) bool {
+	selector, err := f.LabelSelector()
+	if err != nil || !selector.Matches(labels.Set(node.Labels)) {
+		return false
+	}
+
+	for _, rule := range f.IncludeTaints {
+		if !rule.matchesAny(node.Spec.Taints) {
+			return false
+		}
+	}
+	for _, rule := range f.ExcludeTaints {
+		if rule.matchesAny(node.Spec.Taints) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the taint satisfies the rule
+func (r *TaintRule) Matches(taint *v1.Taint) bool {
+	return r.Key == taint.Key &&
+		(r.Value == "" || r.Value == taint.Value) &&
+		(r.Effect == "" || r.Effect == taint.Effect)
+}
+
+func (r *TaintRule) matchesAny(taints []v1.Taint) bool {
+	for i := range taints {
+		if r.Matches(&taints[i]) {
+			return true
+		}
+	}
+	return false
+}
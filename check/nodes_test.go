@@ -0,0 +1,73 @@
+package check_test
+
+import (
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func GiveMeANode(labels map[string]string, taints ...v1.Taint) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: labels},
+		Spec:       v1.NodeSpec{Taints: taints},
+	}
+}
+
+func TestNodeFilter_Matches(t *testing.T) {
+	batch := map[string]string{"pool": "batch"}
+	spot := v1.Taint{Key: "spot", Value: "true", Effect: v1.TaintEffectNoSchedule}
+	gpu := v1.Taint{Key: "nvidia.com/gpu", Effect: v1.TaintEffectNoExecute}
+
+	tests := []struct {
+		name   string
+		filter check.NodeFilter
+		node   *v1.Node
+		want   bool
+	}{
+		{"empty filter", check.NodeFilter{}, GiveMeANode(nil, spot), true},
+		{"selector matches", check.NodeFilter{Selector: "pool=batch"}, GiveMeANode(batch), true},
+		{"selector misses", check.NodeFilter{Selector: "pool=web"}, GiveMeANode(batch), false},
+		{"set based selector", check.NodeFilter{Selector: "pool in (batch,web),!gpu"}, GiveMeANode(batch), true},
+		{"bad selector", check.NodeFilter{Selector: "pool in (batch"}, GiveMeANode(batch), false},
+		{"include by key", check.NodeFilter{IncludeTaints: []check.TaintRule{{Key: "spot"}}}, GiveMeANode(nil, spot), true},
+		{"include missing", check.NodeFilter{IncludeTaints: []check.TaintRule{{Key: "spot"}}}, GiveMeANode(nil, gpu), false},
+		{"include every rule", check.NodeFilter{IncludeTaints: []check.TaintRule{{Key: "spot"}, {Key: "nvidia.com/gpu"}}}, GiveMeANode(nil, spot), false},
+		{"include by value", check.NodeFilter{IncludeTaints: []check.TaintRule{{Key: "spot", Value: "false"}}}, GiveMeANode(nil, spot), false},
+		{"exclude by effect", check.NodeFilter{ExcludeTaints: []check.TaintRule{{Key: "nvidia.com/gpu", Effect: v1.TaintEffectNoExecute}}}, GiveMeANode(nil, spot, gpu), false},
+		{"exclude other effect", check.NodeFilter{ExcludeTaints: []check.TaintRule{{Key: "nvidia.com/gpu", Effect: v1.TaintEffectNoSchedule}}}, GiveMeANode(nil, gpu), true},
+		{"selector and taints", check.NodeFilter{Selector: "pool=batch", ExcludeTaints: []check.TaintRule{{Key: "spot"}}}, GiveMeANode(batch, spot), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.node); got != tt.want {
+				t.Errorf("NodeFilter.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_NodeFilter(t *testing.T) {
+	spec := GiveMeASpec()
+	spec.Nodes = check.NodeFilter{
+		Selector:      "pool in (batch",
+		IncludeTaints: []check.TaintRule{{Key: ""}},
+		ExcludeTaints: []check.TaintRule{{Key: "spot", Effect: "Sometimes"}},
+	}
+	verr := problemsOf(t, check.Validate([]check.Spec{spec}))
+
+	var fields []string
+	for _, p := range verr {
+		fields = append(fields, p.Field)
+	}
+	want := []string{"Nodes.Selector", "Nodes.IncludeTaints[0]", "Nodes.ExcludeTaints[0]"}
+	if len(fields) != len(want) {
+		t.Fatalf("Validate() problems = %v, want %v", fields, want)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("Validate() problems = %v, want %v", fields, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package check
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Prediction scales a check for the capacity it is heading for rather than the
+// capacity it has, so replicas are ready by the time a burst of new nodes is
+type Prediction struct {
+	// Window of recent capacity the linear trend is fitted over, e.g. "10m"
+	Window metav1.Duration `json:"window"`
+	// LeadTime is how far ahead of now the trend is forecast, e.g. "3m"
+	LeadTime metav1.Duration `json:"leadTime"`
+}
+
+// MinPredictionSamples is how many ticks of capacity a check needs within its
+// window before it predicts
+const MinPredictionSamples = 3
+
+// PredictionWindow is how much capacity history the check needs, zero
+// without a prediction
+func (s *Spec) PredictionWindow() time.Duration {
+	if s.Prediction == nil {
+		return 0
+	}
+	return s.Prediction.Window.Duration
+}
+
+// validatePrediction checks the prediction of a check, if any
+func validatePrediction(add addProblem, p *Prediction) {
+	if p == nil {
+		return
+	}
+
+	if p.Window.Duration <= 0 {
+		add("Prediction.Window", "must be positive, got %v", p.Window.Duration)
+	}
+	if p.LeadTime.Duration <= 0 {
+		add("Prediction.LeadTime", "must be positive, got %v", p.LeadTime.Duration)
+	}
+}
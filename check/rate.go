@@ -0,0 +1,184 @@
+package check
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RateRules limit how fast a check scales its target in one direction, like
+// the behavior of a HorizontalPodAutoscaler
+type RateRules struct {
+	// Policies each allow some change per period
+	Policies []RatePolicy `json:"policies,omitempty"`
+	// SelectPolicy picks among the policies, SelectMax when unset
+	SelectPolicy SelectPolicy `json:"selectPolicy,omitempty"`
+}
+
+// RatePolicy allows a change of at most Value pods or percent per Period
+type RatePolicy struct {
+	Type   RatePolicyType  `json:"type"`
+	Value  int32           `json:"value"`
+	Period metav1.Duration `json:"period"`
+}
+
+// RatePolicyType is the unit of a RatePolicy's Value
+type RatePolicyType string
+
+const (
+	PodsRatePolicy    RatePolicyType = "Pods"
+	PercentRatePolicy RatePolicyType = "Percent"
+)
+
+// SelectPolicy decides which policy applies when there are several
+type SelectPolicy string
+
+const (
+	// SelectMax applies the policy allowing the largest change
+	SelectMax SelectPolicy = "Max"
+	// SelectMin applies the policy allowing the smallest change
+	SelectMin SelectPolicy = "Min"
+	// SelectDisabled forbids scaling in this direction
+	SelectDisabled SelectPolicy = "Disabled"
+)
+
+// LongestPeriod is the longest period of any policy, i.e. how much replica
+// history LimitRate needs
+func (s *Spec) LongestPeriod() time.Duration {
+	var longest time.Duration
+	for _, rules := range []*RateRules{s.ScaleUp, s.ScaleDown} {
+		if rules == nil {
+			continue
+		}
+		for _, p := range rules.Policies {
+			if p.Period.Duration > longest {
+				longest = p.Period.Duration
+			}
+		}
+	}
+	return longest
+}
+
+// LimitRate caps the change from current to desired replicas by the scale up
+// or scale down rules.  lowest and highest give the fewest and most replicas
+// the target had within a period, now included.  The reason is empty unless
+// the change was limited.
+func (s *Spec) LimitRate(current, desired int32, lowest, highest func(period time.Duration) int32) (int32, string) {
+	switch {
+	case desired > current && s.ScaleUp != nil:
+		limit, ok := s.ScaleUp.limit(true, current, lowest)
+		if !ok || desired <= limit {
+			return desired, ""
+		}
+		if limit < current {
+			limit = current
+		}
+		return limit, fmt.Sprintf("scale up limited to %d by %s", limit, s.ScaleUp.describe())
+	case desired < current && s.ScaleDown != nil:
+		limit, ok := s.ScaleDown.limit(false, current, highest)
+		if !ok || desired >= limit {
+			return desired, ""
+		}
+		if limit > current {
+			limit = current
+		}
+		return limit, fmt.Sprintf("scale down limited to %d by %s", limit, s.ScaleDown.describe())
+	}
+	return desired, ""
+}
+
+// limit is the furthest the rules allow scaling, ok is false when they don't
+// limit scaling at all.  base gives the replicas each policy counts from.
+func (r *RateRules) limit(up bool, current int32, base func(period time.Duration) int32) (int32, bool) {
+	if r.SelectPolicy == SelectDisabled {
+		return current, true
+	}
+	if len(r.Policies) == 0 {
+		return 0, false
+	}
+
+	// Max allows the largest change, i.e. the highest limit going up and the
+	// lowest going down
+	higher := up == (r.SelectPolicy != SelectMin)
+	limit := r.Policies[0].limit(up, base(r.Policies[0].Period.Duration))
+	for _, p := range r.Policies[1:] {
+		l := p.limit(up, base(p.Period.Duration))
+		if (higher && l > limit) || (!higher && l < limit) {
+			limit = l
+		}
+	}
+	return limit, true
+}
+
+func (p *RatePolicy) limit(up bool, base int32) int32 {
+	var change float64
+	switch p.Type {
+	case PercentRatePolicy:
+		change = float64(base) * float64(p.Value) / 100
+	default:
+		change = float64(p.Value)
+	}
+
+	if up {
+		return saturate(math.Ceil(float64(base) + change))
+	}
+	return saturate(math.Max(0, math.Floor(float64(base)-change)))
+}
+
+func (r *RateRules) describe() string {
+	if r.SelectPolicy == SelectDisabled {
+		return "a disabled policy"
+	}
+	var policies []string
+	for _, p := range r.Policies {
+		unit := " pods"
+		if p.Type == PercentRatePolicy {
+			unit = "%"
+		}
+		policies = append(policies, fmt.Sprintf("%d%s per %s", p.Value, unit, p.Period.Duration))
+	}
+	selectPolicy := r.SelectPolicy
+	if selectPolicy == "" {
+		selectPolicy = SelectMax
+	}
+	return fmt.Sprintf("%s of %v", selectPolicy, policies)
+}
+
+func saturate(f float64) int32 {
+	if f >= math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(f)
+}
+
+// validateRateRules checks the rules of one direction, named by field
+func validateRateRules(add addProblem, field string, r *RateRules) {
+	if r == nil {
+		return
+	}
+
+	switch r.SelectPolicy {
+	case "", SelectMax, SelectMin:
+		if len(r.Policies) == 0 {
+			add(field+".Policies", "must have at least one policy unless SelectPolicy is %s", SelectDisabled)
+		}
+	case SelectDisabled:
+	default:
+		add(field+".SelectPolicy", "must be one of %v, got %q", []SelectPolicy{SelectMax, SelectMin, SelectDisabled}, r.SelectPolicy)
+	}
+
+	for i, p := range r.Policies {
+		policyField := fmt.Sprintf("%s.Policies[%d]", field, i)
+		if p.Type != PodsRatePolicy && p.Type != PercentRatePolicy {
+			add(policyField+".Type", "must be one of %v, got %q", []RatePolicyType{PodsRatePolicy, PercentRatePolicy}, p.Type)
+		}
+		if p.Value <= 0 {
+			add(policyField+".Value", "must be positive, got %d", p.Value)
+		}
+		if p.Period.Duration <= 0 {
+			add(policyField+".Period", "must be positive, got %v", p.Period.Duration)
+		}
+	}
+}
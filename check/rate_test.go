@@ -0,0 +1,92 @@
+package check_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func GiveMeARatePolicy(policyType check.RatePolicyType, value int32, period time.Duration) check.RatePolicy {
+	return check.RatePolicy{Type: policyType, Value: value, Period: metav1.Duration{Duration: period}}
+}
+
+func TestSpec_LimitRate(t *testing.T) {
+	fourPods := GiveMeARatePolicy(check.PodsRatePolicy, 4, time.Minute)
+	doubling := GiveMeARatePolicy(check.PercentRatePolicy, 100, time.Minute)
+	halving := GiveMeARatePolicy(check.PercentRatePolicy, 50, 5*time.Minute)
+
+	// The target had 5 replicas a minute ago, and 10 in the last five minutes
+	lowest := func(period time.Duration) int32 { return 5 }
+	highest := func(period time.Duration) int32 {
+		if period > time.Minute {
+			return 10
+		}
+		return 6
+	}
+
+	tests := []struct {
+		name             string
+		up, down         *check.RateRules
+		current, desired int32
+		want             int32
+		limited          bool
+	}{
+		{"no rules", nil, nil, 5, 80, 80, false},
+		{"pods", &check.RateRules{Policies: []check.RatePolicy{fourPods}}, nil, 5, 80, 9, true},
+		{"within the limit", &check.RateRules{Policies: []check.RatePolicy{fourPods}}, nil, 5, 8, 8, false},
+		{"max of pods and percent", &check.RateRules{Policies: []check.RatePolicy{fourPods, doubling}}, nil, 5, 80, 10, true},
+		{"min of pods and percent", &check.RateRules{Policies: []check.RatePolicy{fourPods, doubling}, SelectPolicy: check.SelectMin}, nil, 5, 80, 9, true},
+		{"already scaled up this period", &check.RateRules{Policies: []check.RatePolicy{fourPods}}, nil, 12, 80, 12, true},
+		{"scale up disabled", &check.RateRules{SelectPolicy: check.SelectDisabled}, nil, 5, 80, 5, true},
+		{"up rules leave scale down alone", &check.RateRules{SelectPolicy: check.SelectDisabled}, nil, 5, 1, 1, false},
+		{"percent down", nil, &check.RateRules{Policies: []check.RatePolicy{halving}}, 6, 1, 5, true},
+		{"max of pods and percent down", nil, &check.RateRules{Policies: []check.RatePolicy{fourPods, halving}}, 6, 1, 2, true},
+		{"min of pods and percent down", nil, &check.RateRules{Policies: []check.RatePolicy{fourPods, halving}, SelectPolicy: check.SelectMin}, 6, 1, 5, true},
+		{"scale down disabled", nil, &check.RateRules{SelectPolicy: check.SelectDisabled}, 6, 1, 6, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.ScaleUp, spec.ScaleDown = tt.up, tt.down
+			got, reason := spec.LimitRate(tt.current, tt.desired, lowest, highest)
+			if got != tt.want || (reason != "") != tt.limited {
+				t.Errorf("check.Spec.LimitRate() = %v, %q, want %v, limited %v", got, reason, tt.want, tt.limited)
+			}
+		})
+	}
+}
+
+func TestSpec_LongestPeriod(t *testing.T) {
+	spec := GiveMeASpec()
+	spec.ScaleUp = &check.RateRules{Policies: []check.RatePolicy{GiveMeARatePolicy(check.PodsRatePolicy, 4, time.Minute)}}
+	spec.ScaleDown = &check.RateRules{Policies: []check.RatePolicy{GiveMeARatePolicy(check.PercentRatePolicy, 10, 10*time.Minute)}}
+	if got := spec.LongestPeriod(); got != 10*time.Minute {
+		t.Errorf("check.Spec.LongestPeriod() = %v, want 10m", got)
+	}
+}
+
+func TestValidate_RateRules(t *testing.T) {
+	spec := GiveMeASpec()
+	spec.ScaleUp = &check.RateRules{SelectPolicy: "Sometimes", Policies: []check.RatePolicy{
+		GiveMeARatePolicy("Replicas", 0, 0),
+	}}
+	spec.ScaleDown = &check.RateRules{}
+
+	var fields []string
+	for _, p := range problemsOf(t, check.Validate([]check.Spec{spec})) {
+		fields = append(fields, p.Field)
+	}
+	want := []string{
+		"ScaleUp.SelectPolicy",
+		"ScaleUp.Policies[0].Type",
+		"ScaleUp.Policies[0].Value",
+		"ScaleUp.Policies[0].Period",
+		"ScaleDown.Policies",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Validate() problems = %v, want %v", fields, want)
+	}
+}
@@ -1,10 +1,46 @@
 package check
 
-import v1 "k8s.io/api/core/v1"
+import (
+	"strings"
 
+	v1 "k8s.io/api/core/v1"
+)
+
+// SupportedResources are the resources with a dedicated per-replica field.
+// Any other resource can be scaled on through Spec.PerReplica.
 func SupportedResources() []v1.ResourceName {
 	return []v1.ResourceName{
 		v1.ResourceCPU,
 		v1.ResourceMemory,
 	}
 }
+
+// PrefixResources name a family of resources, e.g. every huge page size
+func PrefixResources() []v1.ResourceName {
+	return []v1.ResourceName{
+		v1.ResourceHugePagesPrefix,
+	}
+}
+
+// IsPrefixResource reports whether rName stands for every resource starting with it
+func IsPrefixResource(rName v1.ResourceName) bool {
+	return containsResource(PrefixResources(), rName)
+}
+
+// ResourceMatches reports whether the node resource `actual` counts towards the
+// configured resource rName
+func ResourceMatches(rName, actual v1.ResourceName) bool {
+	if IsPrefixResource(rName) {
+		return strings.HasPrefix(string(actual), string(rName))
+	}
+	return rName == actual
+}
+
+func containsResource(list []v1.ResourceName, rName v1.ResourceName) bool {
+	for _, r := range list {
+		if r == rName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package check
+
+import (
+	"math"
+)
+
+// RoundingMode is which way a recommendation is rounded to whole replicas
+type RoundingMode string
+
+const (
+	RoundCeil    RoundingMode = "ceil"
+	RoundFloor   RoundingMode = "floor"
+	RoundNearest RoundingMode = "nearest"
+)
+
+// RoundingModes lists every supported mode, the first one being the default
+func RoundingModes() []RoundingMode {
+	return []RoundingMode{RoundCeil, RoundFloor, RoundNearest}
+}
+
+// Rounding turns a recommendation into a replica count the target can use,
+// e.g. an odd count for a quorum or a multiple of the zones for zone spread
+type Rounding struct {
+	// Mode rounds up, down or to the nearest allowed count, RoundCeil when unset
+	Mode RoundingMode `json:"mode,omitempty"`
+	// MultipleOf allows only multiples of it
+	MultipleOf int32 `json:"multipleOf,omitempty"`
+	// Odd allows only odd counts
+	Odd bool `json:"odd,omitempty"`
+	// MultipleOfZones allows only multiples of the zones of the counted
+	// nodes, going by their topology.kubernetes.io/zone label
+	MultipleOfZones bool `json:"multipleOfZones,omitempty"`
+}
+
+// Round turns a recommendation into a replica count allowed by the check's
+// rounding, given the zones of its nodes.  Zero is always allowed, so a
+// recommendation of zero or less stays zero.
+func (s *Spec) Round(recommendation float64, zones int) int64 {
+	if recommendation <= 0 || math.IsNaN(recommendation) {
+		return 0
+	}
+
+	lower, upper := s.allowed(recommendation, zones)
+	switch s.rounding().Mode {
+	case RoundFloor:
+		return saturate64(lower)
+	case RoundNearest:
+		if recommendation-lower < upper-recommendation {
+			return saturate64(lower)
+		}
+	}
+	return saturate64(upper)
+}
+
+// RoundToward turns a replica count limited on its way from current into one
+// allowed by the check's rounding, whatever its mode, rounding toward current
+// so the limit is never exceeded.  Without an allowed count between the two
+// it stays at current.
+func (s *Spec) RoundToward(replicas, current int32, zones int) int32 {
+	if replicas <= 0 {
+		return 0
+	}
+
+	lower, upper := s.allowed(float64(replicas), zones)
+	switch {
+	case replicas > current && int32(lower) > current:
+		return int32(lower)
+	case replicas < current && int32(upper) < current:
+		return int32(upper)
+	}
+	return current
+}
+
+// allowed finds the closest counts allowed by the check's rounding at or
+// below and at or above a positive recommendation
+func (s *Spec) allowed(recommendation float64, zones int) (lower, upper float64) {
+	r := s.rounding()
+
+	// Allowed counts are offset + k*unit, e.g. 1, 3, 5... for odd ones
+	unit, offset := 1.0, 0.0
+	switch {
+	case r.MultipleOf > 0:
+		unit = float64(r.MultipleOf)
+	case r.MultipleOfZones && zones > 0:
+		unit = float64(zones)
+	}
+	if r.Odd {
+		unit, offset = 2*unit, unit
+	}
+
+	k := (recommendation - offset) / unit
+	upper = offset + math.Max(0, math.Ceil(k))*unit
+	if k >= 0 {
+		lower = offset + math.Floor(k)*unit
+	}
+	return lower, upper
+}
+
+// rounding is the check's rounding, the default one when unset
+func (s *Spec) rounding() *Rounding {
+	if s.Rounding == nil {
+		return &Rounding{}
+	}
+	return s.Rounding
+}
+
+func saturate64(f float64) int64 {
+	if f >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(f)
+}
+
+// validateRounding checks the rounding of a check, if any
+func validateRounding(add addProblem, r *Rounding) {
+	if r == nil {
+		return
+	}
+
+	switch r.Mode {
+	case "", RoundCeil, RoundFloor, RoundNearest:
+	default:
+		add("Rounding.Mode", "must be one of %v, got %q", RoundingModes(), r.Mode)
+	}
+	if r.MultipleOf < 0 {
+		add("Rounding.MultipleOf", "must not be negative, got %d", r.MultipleOf)
+	}
+	if r.MultipleOf > 0 && r.MultipleOfZones {
+		add("Rounding.MultipleOfZones", "must not be set along with MultipleOf")
+	}
+	if r.Odd && r.MultipleOf%2 == 0 && r.MultipleOf > 0 {
+		add("Rounding.Odd", "no multiple of %d is odd", r.MultipleOf)
+	}
+	if r.Odd && r.MultipleOfZones {
+		add("Rounding.Odd", "must not be set along with MultipleOfZones, which may be even")
+	}
+}
@@ -0,0 +1,111 @@
+package check_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+)
+
+func TestSpec_Round(t *testing.T) {
+	tests := []struct {
+		name           string
+		rounding       *check.Rounding
+		recommendation float64
+		zones          int
+		want           int64
+	}{
+		{"default ceil", nil, 4.2, 0, 5},
+		{"whole", nil, 4, 0, 4},
+		{"zero", nil, 0, 0, 0},
+		{"huge", nil, math.Inf(1), 0, math.MaxInt64},
+		{"floor", &check.Rounding{Mode: check.RoundFloor}, 4.8, 0, 4},
+		{"floor below one", &check.Rounding{Mode: check.RoundFloor}, 0.8, 0, 0},
+		{"nearest down", &check.Rounding{Mode: check.RoundNearest}, 4.4, 0, 4},
+		{"nearest up", &check.Rounding{Mode: check.RoundNearest}, 4.5, 0, 5},
+		{"multiple of", &check.Rounding{MultipleOf: 4}, 5, 0, 8},
+		{"multiple of floor", &check.Rounding{Mode: check.RoundFloor, MultipleOf: 4}, 7.9, 0, 4},
+		{"multiple of nearest", &check.Rounding{Mode: check.RoundNearest, MultipleOf: 4}, 9, 0, 8},
+		{"odd", &check.Rounding{Odd: true}, 4, 0, 5},
+		{"odd already", &check.Rounding{Odd: true}, 3, 0, 3},
+		{"odd floor", &check.Rounding{Mode: check.RoundFloor, Odd: true}, 4.5, 0, 3},
+		{"odd below one", &check.Rounding{Odd: true}, 0.2, 0, 1},
+		{"odd nearest", &check.Rounding{Mode: check.RoundNearest, Odd: true}, 5.8, 0, 5},
+		{"odd multiple of three", &check.Rounding{MultipleOf: 3, Odd: true}, 4, 0, 9},
+		{"zones", &check.Rounding{MultipleOfZones: true}, 4, 3, 6},
+		{"zones floor", &check.Rounding{Mode: check.RoundFloor, MultipleOfZones: true}, 4, 3, 3},
+		{"no zones", &check.Rounding{MultipleOfZones: true}, 4.2, 0, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.Rounding = tt.rounding
+			if got := spec.Round(tt.recommendation, tt.zones); got != tt.want {
+				t.Errorf("check.Spec.Round(%v, %d) = %v, want %v", tt.recommendation, tt.zones, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpec_RoundToward(t *testing.T) {
+	tests := []struct {
+		name     string
+		rounding *check.Rounding
+		replicas int32
+		current  int32
+		zones    int
+		want     int32
+	}{
+		{"default", nil, 7, 3, 0, 7},
+		{"up to a multiple", &check.Rounding{MultipleOf: 3}, 7, 3, 0, 6},
+		{"down to a multiple", &check.Rounding{MultipleOf: 3}, 7, 12, 0, 9},
+		{"whatever the mode", &check.Rounding{Mode: check.RoundNearest, MultipleOf: 3}, 8, 3, 0, 6},
+		{"up to an odd count", &check.Rounding{Odd: true}, 8, 5, 0, 7},
+		{"down to an odd count", &check.Rounding{Odd: true}, 8, 11, 0, 9},
+		{"up to a multiple of the zones", &check.Rounding{MultipleOfZones: true}, 8, 3, 3, 6},
+		{"no allowed count in between", &check.Rounding{MultipleOf: 4}, 6, 4, 0, 4},
+		{"down to zero", &check.Rounding{MultipleOf: 3}, 0, 6, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.Rounding = tt.rounding
+			if got := spec.RoundToward(tt.replicas, tt.current, tt.zones); got != tt.want {
+				t.Errorf("check.Spec.RoundToward(%v, %v, %d) = %v, want %v", tt.replicas, tt.current, tt.zones, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate_Rounding(t *testing.T) {
+	tests := []struct {
+		name      string
+		rounding  check.Rounding
+		wantField string
+	}{
+		{"odd multiple", check.Rounding{Mode: check.RoundNearest, MultipleOf: 3, Odd: true}, ""},
+		{"zones", check.Rounding{MultipleOfZones: true}, ""},
+		{"unknown mode", check.Rounding{Mode: "banker"}, "Rounding.Mode"},
+		{"negative multiple", check.Rounding{MultipleOf: -2}, "Rounding.MultipleOf"},
+		{"even multiple and odd", check.Rounding{MultipleOf: 2, Odd: true}, "Rounding.Odd"},
+		{"zones and odd", check.Rounding{MultipleOfZones: true, Odd: true}, "Rounding.Odd"},
+		{"zones and multiple", check.Rounding{MultipleOfZones: true, MultipleOf: 3}, "Rounding.MultipleOfZones"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			rounding := tt.rounding
+			spec.Rounding = &rounding
+			err := check.Validate([]check.Spec{spec})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if verr := problemsOf(t, err); len(verr) != 1 || verr[0].Field != tt.wantField {
+				t.Errorf("Validate() = %v, want a problem with %s", err, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package check
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Schedule overrides a check for Duration from every time its Cron expression
+// fires, e.g. a business hours multiplier or a weekend floor.  It either
+// replaces the replica bounds or multiplies the recommendation.
+type Schedule struct {
+	// Name identifies the schedule in logs and status, its Cron expression
+	// when empty
+	Name string `json:"name,omitempty"`
+	// Cron is a standard five field expression, e.g. "0 8 * * 1-5"
+	Cron string `json:"cron"`
+	// TimeZone Cron is evaluated in, e.g. "Europe/Berlin", UTC when empty
+	TimeZone string `json:"timeZone,omitempty"`
+	// Duration the schedule stays active after each time it fires
+	Duration metav1.Duration `json:"duration"`
+	// MinReplicas and MaxReplicas replace the check's bounds while active
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+	// Multiplier scales the recommendation while active, e.g. 1.5
+	Multiplier float64 `json:"multiplier,omitempty"`
+}
+
+// String names the schedule
+func (sc *Schedule) String() string {
+	if sc.Name != "" {
+		return sc.Name
+	}
+	return sc.Cron
+}
+
+// Active reports whether the schedule fired within Duration before now.  An
+// unparseable schedule is never active, although validation rejects those
+// before they get here.
+func (sc *Schedule) Active(now time.Time) bool {
+	schedule, loc, err := sc.parse()
+	if err != nil {
+		return false
+	}
+	fired := schedule.Next(now.In(loc).Add(-sc.Duration.Duration))
+	return !fired.IsZero() && !fired.After(now)
+}
+
+func (sc *Schedule) parse() (cron.Schedule, *time.Location, error) {
+	loc, err := time.LoadLocation(sc.TimeZone)
+	if err != nil {
+		return nil, nil, err
+	}
+	schedule, err := cron.ParseStandard(sc.Cron)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schedule, loc, nil
+}
+
+// Scale applies the multiplier, if any, to a recommendation
+func (sc *Schedule) Scale(recommendation float64) float64 {
+	if sc.Multiplier == 0 {
+		return recommendation
+	}
+	return recommendation * sc.Multiplier
+}
+
+// ActiveSchedule is the first of the check's schedules active at now, nil when
+// none is
+func (s *Spec) ActiveSchedule(now time.Time) *Schedule {
+	for i := range s.Schedules {
+		if s.Schedules[i].Active(now) {
+			return &s.Schedules[i]
+		}
+	}
+	return nil
+}
+
+// WithSchedule is a copy of the check using the replica bounds the schedule
+// sets in place of its own
+func (s *Spec) WithSchedule(sc *Schedule) Spec {
+	scheduled := *s
+	if sc.MinReplicas != nil {
+		scheduled.MinReplicas = sc.MinReplicas
+	}
+	if sc.MaxReplicas != nil {
+		scheduled.MaxReplicas = sc.MaxReplicas
+	}
+	return scheduled
+}
+
+// validateSchedule checks one of the schedules, named by field
+func validateSchedule(add addProblem, field string, sc *Schedule) {
+	if strings.HasPrefix(sc.Cron, "TZ=") || strings.HasPrefix(sc.Cron, "CRON_TZ=") {
+		add(field+".Cron", "must not set a time zone, use TimeZone")
+	} else if _, err := cron.ParseStandard(sc.Cron); err != nil {
+		add(field+".Cron", "%s", err.Error())
+	}
+	if _, err := time.LoadLocation(sc.TimeZone); err != nil {
+		add(field+".TimeZone", "%s", err.Error())
+	}
+	if sc.Duration.Duration <= 0 {
+		add(field+".Duration", "must be positive, got %v", sc.Duration.Duration)
+	}
+
+	bounded := sc.MinReplicas != nil || sc.MaxReplicas != nil
+	switch {
+	case bounded && sc.Multiplier != 0:
+		add(field, "must set either MinReplicas and MaxReplicas or Multiplier, not both")
+	case !bounded && sc.Multiplier == 0:
+		add(field, "must set MinReplicas, MaxReplicas or Multiplier")
+	}
+	if math.IsNaN(sc.Multiplier) || math.IsInf(sc.Multiplier, 0) || sc.Multiplier < 0 {
+		add(field+".Multiplier", "must be a positive number, got %v", sc.Multiplier)
+	}
+	if sc.MinReplicas != nil && *sc.MinReplicas < 0 {
+		add(field+".MinReplicas", "must not be negative, got %d", *sc.MinReplicas)
+	}
+	if sc.MaxReplicas != nil && *sc.MaxReplicas < 1 {
+		add(field+".MaxReplicas", "must be at least 1, got %d", *sc.MaxReplicas)
+	}
+	if sc.MinReplicas != nil && sc.MaxReplicas != nil && *sc.MinReplicas > *sc.MaxReplicas {
+		add(field+".MaxReplicas", "must not be less than MinReplicas %d, got %d", *sc.MinReplicas, *sc.MaxReplicas)
+	}
+}
@@ -0,0 +1,139 @@
+package check_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func GiveMeASchedule(name, cron, timeZone string, duration time.Duration) check.Schedule {
+	return check.Schedule{Name: name, Cron: cron, TimeZone: timeZone, Duration: metav1.Duration{Duration: duration}, Multiplier: 2}
+}
+
+// at is a fake clock reading, in UTC
+func at(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestSchedule_Active(t *testing.T) {
+	businessHours := GiveMeASchedule("business-hours", "0 8 * * 1-5", "Europe/Berlin", 10*time.Hour)
+	weekend := GiveMeASchedule("weekend", "0 0 * * 6", "", 48*time.Hour)
+
+	tests := []struct {
+		name     string
+		schedule check.Schedule
+		now      time.Time
+		want     bool
+	}{
+		// Berlin is UTC+2 in summer, UTC+1 in winter
+		{"before it fires", businessHours, at("2026-07-01T05:59:59Z"), false},
+		{"when it fires", businessHours, at("2026-07-01T06:00:00Z"), true},
+		{"within duration", businessHours, at("2026-07-01T15:59:59Z"), true},
+		{"after duration", businessHours, at("2026-07-01T16:00:00Z"), false},
+		{"in winter time", businessHours, at("2026-01-14T07:30:00Z"), true},
+		{"on a weekend", businessHours, at("2026-07-04T10:00:00Z"), false},
+		{"saturday", weekend, at("2026-07-04T10:00:00Z"), true},
+		{"sunday night", weekend, at("2026-07-05T23:59:00Z"), true},
+		{"monday", weekend, at("2026-07-06T00:00:00Z"), false},
+		{"unparseable", GiveMeASchedule("bad", "0 8 * *", "", time.Hour), at("2026-07-01T08:00:00Z"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Active(tt.now); got != tt.want {
+				t.Errorf("check.Schedule.Active(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpec_ActiveSchedule(t *testing.T) {
+	spec := GiveMeASpec()
+	spec.Schedules = []check.Schedule{
+		GiveMeASchedule("", "0 8 * * 1-5", "", 10*time.Hour),
+		GiveMeASchedule("daily", "0 0 * * *", "", 24*time.Hour),
+	}
+
+	if got := spec.ActiveSchedule(at("2026-07-01T09:00:00Z")); got == nil || got.String() != "0 8 * * 1-5" {
+		t.Errorf("check.Spec.ActiveSchedule() = %v, want the first active schedule", got)
+	}
+	if got := spec.ActiveSchedule(at("2026-07-01T19:00:00Z")); got == nil || got.String() != "daily" {
+		t.Errorf("check.Spec.ActiveSchedule() = %v, want daily", got)
+	}
+	spec.Schedules = spec.Schedules[:1]
+	if got := spec.ActiveSchedule(at("2026-07-01T19:00:00Z")); got != nil {
+		t.Errorf("check.Spec.ActiveSchedule() = %v, want none", got)
+	}
+}
+
+func TestSpec_WithSchedule(t *testing.T) {
+	bound := func(i int32) *int32 { return &i }
+	spec := GiveMeASpec()
+	spec.MinReplicas, spec.MaxReplicas = bound(2), bound(10)
+
+	floor := check.Schedule{MinReplicas: bound(4)}
+	scheduled := spec.WithSchedule(&floor)
+	if got, bound := scheduled.Clamp(1); got != 4 || bound != check.BoundMin {
+		t.Errorf("check.Spec.Clamp() = %v, %v, want the schedule's floor", got, bound)
+	}
+	if got, bound := scheduled.Clamp(20); got != 10 || bound != check.BoundMax {
+		t.Errorf("check.Spec.Clamp() = %v, %v, want the check's own max", got, bound)
+	}
+	if *spec.MinReplicas != 2 {
+		t.Errorf("check.Spec.WithSchedule() changed the check's MinReplicas to %d", *spec.MinReplicas)
+	}
+
+	double := check.Schedule{Multiplier: 2}
+	if got := double.Scale(2.5); got != 5 {
+		t.Errorf("check.Schedule.Scale() = %v, want 5", got)
+	}
+	if got := floor.Scale(2.5); got != 2.5 {
+		t.Errorf("check.Schedule.Scale() = %v, want 2.5 without a multiplier", got)
+	}
+}
+
+func TestValidate_Schedules(t *testing.T) {
+	bound := func(i int32) *int32 { return &i }
+	valid := GiveMeASchedule("business-hours", "0 8 * * 1-5", "America/New_York", 8*time.Hour)
+
+	tests := []struct {
+		name      string
+		change    func(s *check.Schedule)
+		wantField string
+	}{
+		{"multiplier", func(s *check.Schedule) {}, ""},
+		{"bounds", func(s *check.Schedule) { s.Multiplier, s.MinReplicas, s.MaxReplicas = 0, bound(3), bound(6) }, ""},
+		{"descriptor", func(s *check.Schedule) { s.Cron = "@weekly" }, ""},
+		{"bad cron", func(s *check.Schedule) { s.Cron = "0 8 * *" }, "Schedules[0].Cron"},
+		{"time zone in cron", func(s *check.Schedule) { s.Cron = "CRON_TZ=UTC 0 8 * * *" }, "Schedules[0].Cron"},
+		{"bad time zone", func(s *check.Schedule) { s.TimeZone = "Mars/Olympus_Mons" }, "Schedules[0].TimeZone"},
+		{"no duration", func(s *check.Schedule) { s.Duration.Duration = 0 }, "Schedules[0].Duration"},
+		{"nothing to do", func(s *check.Schedule) { s.Multiplier = 0 }, "Schedules[0]"},
+		{"bounds and multiplier", func(s *check.Schedule) { s.MinReplicas = bound(3) }, "Schedules[0]"},
+		{"negative multiplier", func(s *check.Schedule) { s.Multiplier = -1 }, "Schedules[0].Multiplier"},
+		{"crossed bounds", func(s *check.Schedule) { s.Multiplier, s.MinReplicas, s.MaxReplicas = 0, bound(6), bound(3) }, "Schedules[0].MaxReplicas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := valid
+			tt.change(&schedule)
+			spec := GiveMeASpec()
+			spec.Schedules = []check.Schedule{schedule}
+			err := check.Validate([]check.Spec{spec})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if verr := problemsOf(t, err); len(verr) != 1 || verr[0].Field != tt.wantField {
+				t.Errorf("Validate() = %v, want a problem with %s", err, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package check
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kindAliases maps the short and plural names of the SupportedKinds to them
+var kindAliases = map[string]string{
+	"deploy":       "deployment",
+	"deployments":  "deployment",
+	"rs":           "replicaset",
+	"replicasets":  "replicaset",
+	"sts":          "statefulset",
+	"statefulsets": "statefulset",
+}
+
+// NormalizedKind is the lower case kind, resolving the short and plural names
+// of the SupportedKinds, e.g. "deployment" for "deploy" or "Deployment"
+func (s *ScalingTarget) NormalizedKind() string {
+	kind := strings.ToLower(s.Kind)
+	if alias, ok := kindAliases[kind]; ok && s.Group() == "apps" {
+		return alias
+	}
+	return kind
+}
+
+// Group is the API group of the target, apps when APIVersion is unset
+func (s *ScalingTarget) Group() string {
+	if s.APIVersion == "" {
+		return "apps"
+	}
+	gv, err := schema.ParseGroupVersion(s.APIVersion)
+	if err != nil {
+		return s.APIVersion
+	}
+	return gv.Group
+}
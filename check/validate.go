@@ -0,0 +1,566 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/aggregate"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Position locates a spec in its source so that problems can be reported
+// against what the user actually wrote
+type Position struct {
+	File     string // Empty when reading from a plain io.Reader
+	Document int    // 1-based YAML document, 0 for JSON
+	Index    int    // 0-based position of the spec in its list
+	Line     int    // 1-based line of the start of the spec, 0 when unknown
+	Column   int    // 1-based column of the start of the spec, 0 when unknown
+}
+
+func (p Position) String() string {
+	var loc []string
+	if p.File != "" {
+		loc = append(loc, p.File)
+	}
+	if p.Line > 0 {
+		loc = append(loc, fmt.Sprintf("%d:%d", p.Line, p.Column))
+	}
+
+	item := fmt.Sprintf("item %d", p.Index)
+	if p.Document > 0 {
+		item = fmt.Sprintf("document %d, %s", p.Document, item)
+	}
+	if len(loc) == 0 {
+		return item
+	}
+	return fmt.Sprintf("%s (%s)", strings.Join(loc, ":"), item)
+}
+
+// FieldError is a single problem with a single spec
+type FieldError struct {
+	Position Position
+	Field    string // Dotted path of the offending key, empty for problems with the whole spec
+	Message  string
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.Position, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Position, e.Field, e.Message)
+}
+
+// ValidationError aggregates every problem found in a configuration, so that
+// a broken config can be fixed in one pass
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("%d problem(s) in configuration:\n  %s", len(e), strings.Join(msgs, "\n  "))
+}
+
+// DuplicatePolicy decides what happens when several specs name the same target
+type DuplicatePolicy string
+
+const (
+	// DuplicateGroup keeps every spec, so that each target is reconciled from
+	// all of its checks.  Checks of the same target must have distinct names.
+	DuplicateGroup DuplicatePolicy = "group"
+	// DuplicateError rejects the configuration
+	DuplicateError DuplicatePolicy = "error"
+	// DuplicateMerge folds the specs into the first one, rejecting the
+	// configuration only when they set the same field to different values
+	DuplicateMerge DuplicatePolicy = "merge"
+)
+
+// Duplicates is the policy applied to duplicate targets while loading
+var Duplicates = DuplicateGroup
+
+// ParseDuplicatePolicy validates a policy name, e.g. from the environment
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(strings.ToLower(s)); p {
+	case DuplicateGroup, DuplicateError, DuplicateMerge:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown duplicate target policy %q", s)
+}
+
+// SupportedKinds are the target kinds we know how to scale
+func SupportedKinds() []string {
+	return []string{"deployment", "replicaset", "statefulset"}
+}
+
+// entry is a decoded spec along with where it came from
+type entry struct {
+	Spec     Spec
+	Position Position
+}
+
+// Validate checks every field of every spec and applies the duplicate target
+// policy, reporting all problems at once as a ValidationError
+func Validate(specs []Spec) error {
+	entries := make([]entry, len(specs))
+	for i, s := range specs {
+		entries[i] = entry{Spec: s, Position: Position{Index: i}}
+	}
+
+	_, err := finish(entries, nil)
+	return err
+}
+
+// finish validates decoded entries and resolves duplicates.  Any problem,
+// including those found while decoding, rejects the whole configuration.
+func finish(entries []entry, problems []FieldError) ([]Spec, error) {
+	for _, e := range entries {
+		problems = append(problems, validateSpec(e.Spec, e.Position)...)
+	}
+
+	specList, dupProblems := resolveDuplicates(entries, Duplicates)
+	problems = append(problems, dupProblems...)
+
+	if len(problems) > 0 {
+		return nil, ValidationError(problems)
+	}
+	return specList, nil
+}
+
+// addProblem reports a problem with a field of the spec being validated. The
+// helpers checking parts of a spec are passed validateSpec's, which sets the
+// position of every problem.
+type addProblem func(field, format string, args ...interface{})
+
+func validateSpec(s Spec, pos Position) []FieldError {
+	var problems []FieldError
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, FieldError{Position: pos, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	perReplica := []struct {
+		field string
+		value resource.Quantity
+	}{
+		{"CPUPerReplica", s.CPUPerReplica},
+		{"MemoryPerReplica", s.MemoryPerReplica},
+		{"NodesPerReplica", s.NodesPerReplica},
+		{"SchedulablePodsPerReplica", s.SchedulablePodsPerReplica},
+	}
+	for _, rName := range sortedResourceNames(s.PerReplica) {
+		field := fmt.Sprintf("PerReplica[%s]", rName)
+		perReplica = append(perReplica, struct {
+			field string
+			value resource.Quantity
+		}{field, s.PerReplica[rName]})
+
+		if msgs := validateResourceName(rName); len(msgs) > 0 {
+			add(field, "invalid resource name: %s", strings.Join(msgs, "; "))
+		}
+	}
+	if _, ok := s.PerReplica[v1.ResourceCPU]; ok && !s.CPUPerReplica.IsZero() {
+		add("PerReplica[cpu]", "conflicts with CPUPerReplica, set only one")
+	}
+	if _, ok := s.PerReplica[v1.ResourceMemory]; ok && !s.MemoryPerReplica.IsZero() {
+		add("PerReplica[memory]", "conflicts with MemoryPerReplica, set only one")
+	}
+
+	allZero := true
+	for _, pr := range perReplica {
+		if pr.value.Sign() < 0 {
+			add(pr.field, "must not be negative, got %v", pr.value.String())
+		}
+		allZero = allZero && pr.value.IsZero()
+	}
+
+	switch s.Mode {
+	case "", ModeLinear:
+		if allZero {
+			add("", "at least one of CPUPerReplica, MemoryPerReplica, PerReplica, NodesPerReplica or SchedulablePodsPerReplica must be set")
+		}
+		if s.Ladder != nil {
+			add("Ladder", "only used in %s mode", ModeLadder)
+		}
+	case ModeLadder:
+		if !allZero {
+			add("", "CPUPerReplica, MemoryPerReplica, PerReplica, NodesPerReplica and SchedulablePodsPerReplica are only used in %s mode", ModeLinear)
+		}
+		validateLadder(add, s.Ladder)
+	default:
+		add("Mode", "must be one of %v, got %q", Modes(), s.Mode)
+	}
+
+	t := s.Target
+	if t.Name == "" {
+		add("Target.Name", "must not be empty")
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(s.targetName()) {
+			add("Target.Name", "%s", msg)
+		}
+		if s.PerZone && !strings.Contains(t.Name, ZonePlaceholder) {
+			add("Target.Name", "must contain %s in a per zone check", ZonePlaceholder)
+		}
+	}
+	if t.Namespace == "" {
+		add("Target.Namespace", "must not be empty")
+	} else {
+		for _, msg := range validation.IsDNS1123Label(t.Namespace) {
+			add("Target.Namespace", "%s", msg)
+		}
+	}
+	switch {
+	case t.Kind == "":
+		add("Target.Kind", "must not be empty")
+	case t.APIVersion == "":
+		if !contains(SupportedKinds(), t.NormalizedKind()) {
+			add("Target.Kind", "must be one of %v or their short names unless APIVersion is set, got %q", SupportedKinds(), t.Kind)
+		}
+	default:
+		if _, err := schema.ParseGroupVersion(t.APIVersion); err != nil {
+			add("Target.APIVersion", "%s", err.Error())
+		}
+		for _, msg := range validation.IsDNS1035Label(strings.ToLower(t.Kind)) {
+			add("Target.Kind", "%s", msg)
+		}
+	}
+
+	if s.Capacity != "" && !containsCapacitySource(CapacitySources(), s.Capacity) {
+		add("Capacity", "must be one of %v, got %q", CapacitySources(), s.Capacity)
+	}
+	if s.CapacitySource() == CapacityUsage {
+		for _, rName := range s.Resources() {
+			if !containsResource(UsageResources(), rName) {
+				add(fmt.Sprintf("PerReplica[%s]", rName), "%s capacity only measures %v", CapacityUsage, UsageResources())
+			}
+		}
+		if s.Mode == ModeLadder && s.Ladder != nil && !s.Ladder.IsNodes() && !containsResource(UsageResources(), s.Ladder.Resource()) {
+			add("Ladder.Input", "%s capacity only measures %v and %s", CapacityUsage, UsageResources(), InputNodes)
+		}
+	}
+	if !s.Pods.IsZero() && s.CapacitySource() != CapacityRequests {
+		add("Pods", "only used with %s capacity", CapacityRequests)
+	}
+	if s.Pods.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(s.Pods.Namespace) {
+			add("Pods.Namespace", "%s", msg)
+		}
+	}
+	if _, err := labels.Parse(s.Pods.Selector); err != nil {
+		add("Pods.Selector", "%s", err.Error())
+	}
+	if s.MetricsMaxAge.Duration < 0 {
+		add("MetricsMaxAge", "must not be negative, got %v", s.MetricsMaxAge.Duration)
+	}
+
+	if _, err := aggregate.Parse(string(s.Aggregation)); err != nil {
+		add("Aggregation", "%s", err.Error())
+	}
+	if len(s.Weights) > 0 && s.Aggregation != aggregate.WeightedSum {
+		add("Weights", "only used by the %s aggregation", aggregate.WeightedSum)
+	}
+	inputs := s.Inputs()
+	for _, rName := range sortedWeightNames(s.Weights) {
+		field := fmt.Sprintf("Weights[%s]", rName)
+		if !containsResource(inputs, rName) {
+			add(field, "the check doesn't scale on %s", rName)
+		}
+		if w := s.Weights[rName]; w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			add(field, "must be a finite, non-negative number, got %v", w)
+		}
+	}
+
+	if s.Tolerance < 0 || s.Tolerance >= 1 || math.IsNaN(s.Tolerance) {
+		add("Tolerance", "must be a fraction from 0 up to 1, got %v", s.Tolerance)
+	}
+	if s.ScaleDownStabilization.Duration < 0 {
+		add("ScaleDownStabilization", "must not be negative, got %v", s.ScaleDownStabilization.Duration)
+	}
+
+	validateRateRules(add, "ScaleUp", s.ScaleUp)
+	validateRateRules(add, "ScaleDown", s.ScaleDown)
+	validateRounding(add, s.Rounding)
+	validateEligibility(add, &s.Eligibility)
+	validatePrediction(add, s.Prediction)
+	validateHPA(add, s.HPA)
+	for i := range s.Schedules {
+		validateSchedule(add, fmt.Sprintf("Schedules[%d]", i), &s.Schedules[i])
+	}
+
+	if s.MinReplicas != nil && *s.MinReplicas < 0 {
+		add("MinReplicas", "must not be negative, got %d", *s.MinReplicas)
+	}
+	if s.MaxReplicas != nil && *s.MaxReplicas < 1 {
+		add("MaxReplicas", "must be at least 1, got %d", *s.MaxReplicas)
+	}
+	if s.MinReplicas != nil && s.MaxReplicas != nil && *s.MinReplicas > *s.MaxReplicas {
+		add("MaxReplicas", "must not be less than MinReplicas %d, got %d", *s.MinReplicas, *s.MaxReplicas)
+	}
+
+	if _, err := s.Nodes.LabelSelector(); err != nil {
+		add("Nodes.Selector", "%s", err.Error())
+	}
+	for i, rule := range s.Nodes.IncludeTaints {
+		for _, msg := range validateTaintRule(rule) {
+			add(fmt.Sprintf("Nodes.IncludeTaints[%d]", i), "%s", msg)
+		}
+	}
+	for i, rule := range s.Nodes.ExcludeTaints {
+		for _, msg := range validateTaintRule(rule) {
+			add(fmt.Sprintf("Nodes.ExcludeTaints[%d]", i), "%s", msg)
+		}
+	}
+
+	return problems
+}
+
+func validateTaintRule(rule TaintRule) []string {
+	var msgs []string
+	if rule.Key == "" {
+		msgs = append(msgs, "key must not be empty")
+	} else {
+		msgs = append(msgs, validation.IsQualifiedName(rule.Key)...)
+	}
+	if rule.Effect != "" && !containsEffect(SupportedTaintEffects(), rule.Effect) {
+		msgs = append(msgs, fmt.Sprintf("effect must be one of %v, got %q", SupportedTaintEffects(), rule.Effect))
+	}
+	return msgs
+}
+
+func containsEffect(list []v1.TaintEffect, effect v1.TaintEffect) bool {
+	for _, e := range list {
+		if e == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// validateResourceName accepts anything a node can report as allocatable:
+// standard names like ephemeral-storage, hugepages-2Mi and pods, extended
+// resources like example.com/fpga, and the hugepages- prefix
+func validateResourceName(rName v1.ResourceName) []string {
+	if IsPrefixResource(rName) {
+		return nil
+	}
+	return validation.IsQualifiedName(string(rName))
+}
+
+func sortedResourceNames(m map[v1.ResourceName]resource.Quantity) []v1.ResourceName {
+	names := make([]v1.ResourceName, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func sortedWeightNames(m map[v1.ResourceName]float64) []v1.ResourceName {
+	names := make([]v1.ResourceName, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// resolveDuplicates applies the duplicate target policy, keeping the specs in
+// the order their targets were first seen
+func resolveDuplicates(entries []entry, policy DuplicatePolicy) ([]Spec, []FieldError) {
+	var specList []Spec
+	var problems []FieldError
+	firstSeen := make(map[string]int)
+	firstPosition := make(map[string]Position)
+	namePosition := make(map[string]Position)
+
+	for _, e := range entries {
+		if policy == DuplicateGroup {
+			// Names tell the checks of a target apart in history and status,
+			// so at most one of them may be unnamed
+			nameKey := e.Spec.TargetKey() + "#" + e.Spec.Name
+			if first, ok := namePosition[nameKey]; ok {
+				message := fmt.Sprintf("duplicate check %q for target %s, already configured at %s", e.Spec.Name, e.Spec.TargetKey(), first)
+				if e.Spec.Name == "" {
+					message = fmt.Sprintf("another unnamed check for target %s, already configured at %s, name them apart", e.Spec.TargetKey(), first)
+				}
+				problems = append(problems, FieldError{Position: e.Position, Field: "Name", Message: message})
+				continue
+			}
+			namePosition[nameKey] = e.Position
+			specList = append(specList, e.Spec)
+			continue
+		}
+
+		idx, ok := firstSeen[e.Spec.TargetKey()]
+		if !ok {
+			firstSeen[e.Spec.TargetKey()] = len(specList)
+			firstPosition[e.Spec.TargetKey()] = e.Position
+			specList = append(specList, e.Spec)
+			continue
+		}
+		first := firstPosition[e.Spec.TargetKey()]
+
+		switch policy {
+		case DuplicateMerge:
+			for _, field := range mergeSpec(&specList[idx], e.Spec) {
+				problems = append(problems, FieldError{
+					Position: e.Position,
+					Field:    field,
+					Message:  fmt.Sprintf("conflicts with the value for the same target at %s", first),
+				})
+			}
+			logger.Info("Merged duplicate configuration", "deploymentKey", e.Spec.TargetKey(), "checkSpec", e.Spec.Name, "into", specList[idx].Name)
+		default:
+			problems = append(problems, FieldError{
+				Position: e.Position,
+				Field:    "Target",
+				Message:  fmt.Sprintf("duplicate target %s, already configured at %s", e.Spec.TargetKey(), first),
+			})
+		}
+	}
+
+	return specList, problems
+}
+
+// mergeSpec copies every field set in src but not in dst, returning the names
+// of the fields set to different values in both
+func mergeSpec(dst *Spec, src Spec) []string {
+	var conflicts []string
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src)
+
+	for i := 0; i < dv.NumField(); i++ {
+		name := dv.Type().Field(i).Name
+		if name == "Name" || name == "Target" || name == "Source" {
+			continue
+		}
+		df, sf := dv.Field(i), sv.Field(i)
+		switch {
+		case sf.IsZero():
+		case df.IsZero():
+			df.Set(sf)
+		case !equality.Semantic.DeepEqual(df.Interface(), sf.Interface()):
+			conflicts = append(conflicts, name)
+		}
+	}
+
+	return conflicts
+}
+
+// unknownFields walks decoded JSON alongside the Go type it is destined for
+// and reports every key that would be dropped.  Keys match case-insensitively,
+// the same way encoding/json does.
+func unknownFields(v interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+		// Types that decode themselves, e.g. quantities, are leaves
+		return nil
+	}
+
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(obj) {
+			f, ok := fields[strings.ToLower(key)]
+			if !ok {
+				unknown = append(unknown, joinPath(path, key))
+				continue
+			}
+			unknown = append(unknown, unknownFields(obj[key], f.Type, joinPath(path, key))...)
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range list {
+			unknown = append(unknown, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(obj) {
+			unknown = append(unknown, unknownFields(obj[key], t.Elem(), joinPath(path, key))...)
+		}
+	}
+
+	return unknown
+}
+
+// jsonFields indexes the exported fields of a struct by lower-cased JSON name,
+// flattening inlined embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			} else if f.Anonymous {
+				name = ""
+			}
+		} else if f.Anonymous {
+			name = ""
+		}
+
+		if name == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		fields[strings.ToLower(name)] = f
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
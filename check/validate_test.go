@@ -0,0 +1,419 @@
+package check_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/aggregate"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func problemsOf(t *testing.T, err error) check.ValidationError {
+	t.Helper()
+	var verr check.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	return verr
+}
+
+func TestFromReader_ReportsEveryProblem(t *testing.T) {
+	config := `[
+  {"CPUPerReplica": 16, "Target": {"Kind": "deployment", "Name": "ok", "Namespace": "default"}},
+  {"CPUPerReplica": -1, "Target": {"Type": "deployment", "Name": "typo", "Namespace": "default"}},
+    {"Name": "nothing", "Target": {"Kind": "daemonset", "Name": "", "Namespace": "default"}, "Extra": true}
+]`
+	_, err := check.FromReader(strings.NewReader(config))
+	verr := problemsOf(t, err)
+
+	type problem struct {
+		line, column int
+		field        string
+	}
+	var got []problem
+	for _, fe := range verr {
+		got = append(got, problem{fe.Position.Line, fe.Position.Column, fe.Field})
+	}
+	want := []problem{
+		{3, 3, "Target.Type"},
+		{4, 5, "Extra"},
+		{3, 3, "CPUPerReplica"},
+		{3, 3, "Target.Kind"},
+		{4, 5, ""},
+		{4, 5, "Target.Name"},
+		{4, 5, "Target.Kind"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromReader() problems = %v, want %v\n%v", got, want, err)
+	}
+}
+
+func TestFromReader_PositionOfTypeErrors(t *testing.T) {
+	config := "[\n  {\"Name\": 1234, \"CPUPerReplica\": 1, \"Target\": {\"Kind\": \"deployment\", \"Name\": \"a\", \"Namespace\": \"default\"}}\n]"
+	_, err := check.FromReader(strings.NewReader(config))
+	verr := problemsOf(t, err)
+
+	if verr[0].Field != "Name" || verr[0].Position.Line != 2 || verr[0].Position.Column != 16 {
+		t.Errorf("type error should point just past the value, got %v", verr[0])
+	}
+}
+
+func TestFromReader_MalformedQuantity(t *testing.T) {
+	config := `[{"CPUPerReplica": "lots", "MemoryPerReplica": "100Gi", "Target": {"Kind": "deployment", "Name": "a", "Namespace": "default"}}]`
+	_, err := check.FromReader(strings.NewReader(config))
+	verr := problemsOf(t, err)
+
+	if len(verr) != 1 || verr[0].Field != "CPUPerReplica" {
+		t.Errorf("a malformed quantity should be attributed to its key, got %v", err)
+	}
+}
+
+func TestFromReader_SyntaxError(t *testing.T) {
+	_, err := check.FromReader(strings.NewReader("[\n  {\"CPUPerReplica\": 16,}\n]"))
+	verr := problemsOf(t, err)
+	if verr[0].Position.Line != 2 {
+		t.Errorf("syntax error should be on line 2, got %v", verr[0])
+	}
+
+	_, err = check.FromReader(strings.NewReader(`{"CPUPerReplica": 16}`))
+	problemsOf(t, err)
+}
+
+func TestFromYAMLReader_Positions(t *testing.T) {
+	config := `cpuPerReplica: 1
+target: {kind: deployment, name: a, namespace: default}
+---
+- cpuPerReplica: 1
+  target: {kind: deployment, name: b, namespace: default}
+- cpuPerReplica: 1
+  target: {kind: deployment, name: c, namespace: default, type: deployment}
+`
+	_, err := check.FromYAMLReader(strings.NewReader(config))
+	verr := problemsOf(t, err)
+	if len(verr) != 1 {
+		t.Fatalf("expected one problem, got %v", err)
+	}
+	if p := verr[0].Position; p.Document != 2 || p.Index != 1 || verr[0].Field != "target.type" {
+		t.Errorf("problem should be located in document 2, item 1, got %v", verr[0])
+	}
+}
+
+func TestValidate_Duplicates(t *testing.T) {
+	defer func(p check.DuplicatePolicy) { check.Duplicates = p }(check.Duplicates)
+
+	target := GiveMeATarget()
+	cpu := check.Spec{Name: "cpu", CPUPerReplica: resource.MustParse("16"), Target: target}
+	memory := check.Spec{Name: "memory", MemoryPerReplica: resource.MustParse("100e9"), Target: target}
+	otherCPU := check.Spec{Name: "other cpu", CPUPerReplica: resource.MustParse("8"), Target: target}
+
+	tests := []struct {
+		name    string
+		policy  check.DuplicatePolicy
+		specs   []check.Spec
+		wantErr bool
+	}{
+		{"error policy rejects duplicates", check.DuplicateError, []check.Spec{cpu, memory}, true},
+		{"merge policy combines fields", check.DuplicateMerge, []check.Spec{cpu, memory}, false},
+		{"merge policy rejects conflicts", check.DuplicateMerge, []check.Spec{cpu, otherCPU}, true},
+		{"merge policy accepts identical values", check.DuplicateMerge, []check.Spec{cpu, cpu}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check.Duplicates = tt.policy
+			err := check.Validate(tt.specs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	check.Duplicates = check.DuplicateMerge
+	got, err := check.FromReader(strings.NewReader(`[
+  {"Name": "cpu", "CPUPerReplica": 16, "Target": {"Kind": "deployment", "Name": "name", "Namespace": "default"}},
+  {"Name": "memory", "MemoryPerReplica": 1e9, "Target": {"Kind": "deployment", "Name": "name", "Namespace": "default"}}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []check.Spec{{Name: "cpu", CPUPerReplica: resource.MustParse("16"), MemoryPerReplica: resource.MustParse("1e9"), Target: target}}
+	if !equality.Semantic.DeepEqual(got, want) {
+		t.Errorf("FromReader() = %v, want %v", got, want)
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	if p, err := check.ParseDuplicatePolicy("Merge"); err != nil || p != check.DuplicateMerge {
+		t.Errorf("ParseDuplicatePolicy(Merge) = %v, %v", p, err)
+	}
+	if _, err := check.ParseDuplicatePolicy("ignore"); err == nil {
+		t.Errorf("ParseDuplicatePolicy(ignore) should fail")
+	}
+}
+
+func TestValidate_GroupPolicy(t *testing.T) {
+	target := GiveMeATarget()
+	cpu := check.Spec{Name: "cpu", CPUPerReplica: resource.MustParse("16"), Target: target}
+	memory := check.Spec{Name: "memory", MemoryPerReplica: resource.MustParse("100e9"), Target: target}
+
+	if err := check.Validate([]check.Spec{cpu, memory}); err != nil {
+		t.Errorf("Validate() should accept several checks per target by default, got %v", err)
+	}
+	err := check.Validate([]check.Spec{cpu, memory, cpu})
+	if verr := problemsOf(t, err); len(verr) != 1 || verr[0].Field != "Name" || verr[0].Position.Index != 2 {
+		t.Errorf("Validate() should reject the repeated check name, got %v", err)
+	}
+
+	unnamed := check.Spec{CPUPerReplica: resource.MustParse("16"), Target: target}
+	if err := check.Validate([]check.Spec{unnamed, memory}); err != nil {
+		t.Errorf("Validate() should accept a single unnamed check per target, got %v", err)
+	}
+	err = check.Validate([]check.Spec{unnamed, memory, unnamed})
+	if verr := problemsOf(t, err); len(verr) != 1 || verr[0].Field != "Name" || verr[0].Position.Index != 2 {
+		t.Errorf("Validate() should reject a second unnamed check, got %v", err)
+	}
+}
+
+func TestValidate_PerReplica(t *testing.T) {
+	target := GiveMeATarget()
+	perReplica := func(pairs ...string) map[v1.ResourceName]resource.Quantity {
+		m := make(map[v1.ResourceName]resource.Quantity)
+		for i := 0; i < len(pairs); i += 2 {
+			m[v1.ResourceName(pairs[i])] = resource.MustParse(pairs[i+1])
+		}
+		return m
+	}
+	tests := []struct {
+		name      string
+		spec      check.Spec
+		wantErr   bool
+		wantField string
+	}{
+		{"nodes only", check.Spec{NodesPerReplica: resource.MustParse("10"), Target: target}, false, ""},
+		{"schedulable pods only", check.Spec{SchedulablePodsPerReplica: resource.MustParse("500"), Target: target}, false, ""},
+		{"negative nodes", check.Spec{NodesPerReplica: resource.MustParse("-10"), Target: target}, true, "NodesPerReplica"},
+		{"extended resources", check.Spec{PerReplica: perReplica("ephemeral-storage", "10Gi", "hugepages-", "1Gi", "example.com/fpga", "1"), Target: target}, false, ""},
+		{"only zero values", check.Spec{PerReplica: perReplica("pods", "0"), Target: target}, true, ""},
+		{"negative value", check.Spec{PerReplica: perReplica("pods", "-1"), Target: target}, true, "PerReplica[pods]"},
+		{"invalid name", check.Spec{PerReplica: perReplica("not a resource", "1"), Target: target}, true, "PerReplica[not a resource]"},
+		{"conflicts with shorthand", check.Spec{CPUPerReplica: resource.MustParse("16"), PerReplica: perReplica("cpu", "8"), Target: target}, true, "PerReplica[cpu]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := check.Validate([]check.Spec{tt.spec})
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			verr := problemsOf(t, err)
+			found := false
+			for _, p := range verr {
+				found = found || p.Field == tt.wantField
+			}
+			if !found {
+				t.Errorf("Validate() = %v, want a problem with %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidate_ReplicaBounds(t *testing.T) {
+	bound := func(i int32) *int32 { return &i }
+	tests := []struct {
+		name      string
+		min, max  *int32
+		wantField string
+	}{
+		{"unbounded", nil, nil, ""},
+		{"bounded", bound(0), bound(1), ""},
+		{"negative min", bound(-1), nil, "MinReplicas"},
+		{"zero max", nil, bound(0), "MaxReplicas"},
+		{"crossed", bound(5), bound(4), "MaxReplicas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.MinReplicas, spec.MaxReplicas = tt.min, tt.max
+			err := check.Validate([]check.Spec{spec})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if verr := problemsOf(t, err); len(verr) != 1 || verr[0].Field != tt.wantField {
+				t.Errorf("Validate() = %v, want a problem with %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidate_Aggregation(t *testing.T) {
+	tests := []struct {
+		name        string
+		aggregation aggregate.Strategy
+		weights     map[v1.ResourceName]float64
+		wantFields  []string
+	}{
+		{"default", "", nil, nil},
+		{"geometric mean", aggregate.GeometricMean, nil, nil},
+		{"weighted", aggregate.WeightedSum, map[v1.ResourceName]float64{v1.ResourceCPU: 0.75, v1.ResourceMemory: 0.25}, nil},
+		{"unknown", "median", nil, []string{"Aggregation"}},
+		{"weights without weighted sum", aggregate.Max, map[v1.ResourceName]float64{v1.ResourceCPU: 1}, []string{"Weights"}},
+		{"weight of nodes", aggregate.WeightedSum, map[v1.ResourceName]float64{check.InputNodes: 1}, []string{"Weights[nodes]"}},
+		{"weight of another resource", aggregate.WeightedSum, map[v1.ResourceName]float64{v1.ResourcePods: 1}, []string{"Weights[pods]"}},
+		{"negative weight", aggregate.WeightedSum, map[v1.ResourceName]float64{v1.ResourceCPU: -1}, []string{"Weights[cpu]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.Aggregation, spec.Weights = tt.aggregation, tt.weights
+			err := check.Validate([]check.Spec{spec})
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			var fields []string
+			for _, p := range problemsOf(t, err) {
+				fields = append(fields, p.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("Validate() problems = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidate_Stabilization(t *testing.T) {
+	config := `[{"cpuPerReplica": 16, "tolerance": 0.1, "scaleDownStabilization": "5m", "target": {"kind": "deployment", "name": "a", "namespace": "default"}}]`
+	got, err := check.FromReader(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Tolerance != 0.1 || got[0].ScaleDownStabilization.Duration != 5*time.Minute {
+		t.Errorf("FromReader() = %+v, want a 10%% tolerance and a 5m window", got[0])
+	}
+
+	spec := GiveMeASpec()
+	spec.Tolerance = 1.5
+	spec.ScaleDownStabilization.Duration = -time.Minute
+	var fields []string
+	for _, p := range problemsOf(t, check.Validate([]check.Spec{spec})) {
+		fields = append(fields, p.Field)
+	}
+	if want := []string{"Tolerance", "ScaleDownStabilization"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Validate() problems = %v, want %v", fields, want)
+	}
+}
+
+func TestValidate_Capacity(t *testing.T) {
+	spec := GiveMeASpec()
+	spec.Capacity = check.CapacityUsage
+	if err := check.Validate([]check.Spec{spec}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if got := spec.MaxMetricsAge(); got != check.DefaultMetricsMaxAge {
+		t.Errorf("check.Spec.MaxMetricsAge() = %v, want the default", got)
+	}
+
+	usage := spec
+	usage.PerReplica = map[v1.ResourceName]resource.Quantity{"ephemeral-storage": resource.MustParse("100Gi")}
+	if verr := problemsOf(t, check.Validate([]check.Spec{usage})); len(verr) != 1 || verr[0].Field != "PerReplica[ephemeral-storage]" {
+		t.Errorf("Validate() should reject usage capacity of a resource without metrics, got %v", verr)
+	}
+	ladder := check.Spec{Name: "gpu", Mode: check.ModeLadder, Capacity: check.CapacityUsage, Target: spec.Target, Ladder: &check.Ladder{
+		Input: "nvidia.com/gpu",
+		Steps: []check.Step{{Threshold: resource.MustParse("1"), Replicas: 2}},
+	}}
+	if verr := problemsOf(t, check.Validate([]check.Spec{ladder})); len(verr) != 1 || verr[0].Field != "Ladder.Input" {
+		t.Errorf("Validate() should reject a usage ladder on a resource without metrics, got %v", verr)
+	}
+	ladder.Ladder.Input = string(check.InputNodes)
+	if err := check.Validate([]check.Spec{ladder}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	spec.Capacity = check.CapacityRequests
+	spec.Pods = check.PodFilter{Namespace: "batch", Selector: "app!=cron"}
+	if err := check.Validate([]check.Spec{spec}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	spec.Capacity = "requested-ish"
+	spec.MetricsMaxAge.Duration = -time.Minute
+	spec.Pods = check.PodFilter{Namespace: "Batch", Selector: "app in (cron"}
+	var fields []string
+	for _, p := range problemsOf(t, check.Validate([]check.Spec{spec})) {
+		fields = append(fields, p.Field)
+	}
+	if want := []string{"Capacity", "Pods", "Pods.Namespace", "Pods.Selector", "MetricsMaxAge"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Validate() problems = %v, want %v", fields, want)
+	}
+}
+
+func TestValidate_Prediction(t *testing.T) {
+	config := `[{"cpuPerReplica": 16, "prediction": {"window": "10m", "leadTime": "3m"}, "target": {"kind": "deployment", "name": "a", "namespace": "default"}}]`
+	got, err := check.FromReader(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].PredictionWindow() != 10*time.Minute || got[0].Prediction.LeadTime.Duration != 3*time.Minute {
+		t.Errorf("FromReader() = %+v, want a 10m window and a 3m lead time", got[0].Prediction)
+	}
+
+	spec := GiveMeASpec()
+	if spec.PredictionWindow() != 0 {
+		t.Errorf("check.Spec.PredictionWindow() = %v, want none without a prediction", spec.PredictionWindow())
+	}
+	spec.Prediction = &check.Prediction{}
+	spec.Prediction.LeadTime.Duration = -time.Minute
+	var fields []string
+	for _, p := range problemsOf(t, check.Validate([]check.Spec{spec})) {
+		fields = append(fields, p.Field)
+	}
+	if want := []string{"Prediction.Window", "Prediction.LeadTime"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Validate() problems = %v, want %v", fields, want)
+	}
+}
+
+func TestValidate_TargetKind(t *testing.T) {
+	tests := []struct {
+		name       string
+		kind       string
+		apiVersion string
+		wantField  string
+	}{
+		{"supported kind", "statefulset", "", ""},
+		{"short name", "deploy", "", ""},
+		{"capitalized", "Deployment", "", ""},
+		{"custom resource", "Rollout", "argoproj.io/v1alpha1", ""},
+		{"unknown kind without apiVersion", "Rollout", "", "Target.Kind"},
+		{"bad apiVersion", "Rollout", "argoproj.io/v1/alpha1", "Target.APIVersion"},
+		{"bad kind", "Roll.out", "argoproj.io/v1alpha1", "Target.Kind"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := GiveMeASpec()
+			spec.Target.Kind, spec.Target.APIVersion = tt.kind, tt.apiVersion
+			err := check.Validate([]check.Spec{spec})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if verr := problemsOf(t, err); len(verr) != 1 || verr[0].Field != tt.wantField {
+				t.Errorf("Validate() = %v, want a problem with %s", err, tt.wantField)
+			}
+		})
+	}
+}
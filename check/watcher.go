@@ -0,0 +1,176 @@
+package check
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Snapshot is a validated configuration.  Snapshots are never modified once
+// published by a Watcher.
+type Snapshot struct {
+	Specs []Spec
+	// Generation increments every time a configuration with new content is loaded
+	Generation int64
+	// Hash is the sha256 of the file names and contents the specs were read from
+	Hash     string
+	LoadedAt time.Time
+}
+
+// Watcher keeps the last known good configuration of a file or directory,
+// reloading it whenever the files change.  A config which fails to load or
+// validate is logged and reported by HealthCheck, but never replaces the
+// config already in use.
+type Watcher struct {
+	path string
+
+	// ResyncPeriod reloads the config even without a file event, in case one is missed
+	ResyncPeriod time.Duration
+	// Debounce waits for a burst of file events to settle before reloading
+	Debounce time.Duration
+
+	current atomic.Value // *Snapshot
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func NewWatcher(path string) *Watcher {
+	return &Watcher{
+		path:         path,
+		ResyncPeriod: time.Minute,
+		Debounce:     100 * time.Millisecond,
+	}
+}
+
+// Current is the last known good configuration, or nil until one has loaded
+func (w *Watcher) Current() *Snapshot {
+	s, _ := w.current.Load().(*Snapshot)
+	return s
+}
+
+// LastError is the error of the most recent load, nil if it succeeded
+func (w *Watcher) LastError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+// Load reads and validates the config, swapping it in if it is valid and has
+// changed.  A missing path is an empty config until something has loaded, after
+// which it is treated like any other failure.
+func (w *Watcher) Load() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	h := sha256.New()
+	specs, err := fromPath(w.path, h)
+	if os.IsNotExist(err) && w.Current() == nil {
+		specs, err = nil, nil
+	}
+	w.lastErr = err
+
+	previous := w.Current()
+	if err != nil {
+		if previous != nil {
+			logger.Error(err, "Invalid configuration, keeping the last good one", "path", w.path, "generation", previous.Generation, "hash", previous.Hash)
+		} else {
+			logger.Error(err, "Invalid configuration, no good configuration loaded yet", "path", w.path)
+		}
+		return err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+	if previous != nil && previous.Hash == hash {
+		return nil
+	}
+
+	next := &Snapshot{Specs: specs, Hash: hash, LoadedAt: time.Now()}
+	if previous != nil {
+		next.Generation = previous.Generation + 1
+	} else {
+		next.Generation = 1
+	}
+	w.current.Store(next)
+	logger.Info("Loaded configuration", "path", w.path, "generation", next.Generation, "hash", next.Hash, "checks", len(specs))
+
+	return nil
+}
+
+// Run reloads the config whenever its files change, until ctx is done.  Whole
+// directories are watched rather than files, since kubelet updates a ConfigMap
+// volume by swapping the `..data` symlink and editors often replace files.
+func (w *Watcher) Run(ctx context.Context) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsWatcher.Close()
+
+	for _, dir := range w.watchedDirs() {
+		if err := fsWatcher.Add(dir); err != nil {
+			logger.Error(err, "Unable to watch configuration directory, relying on resync", "dir", dir)
+		}
+	}
+
+	resync := time.NewTicker(w.ResyncPeriod)
+	defer resync.Stop()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+			logger.V(3).Info("Configuration file event", "event", event.String())
+			debounce = time.After(w.Debounce)
+		case err, ok := <-fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error(err, "Configuration watch error")
+		case <-debounce:
+			debounce = nil
+			_ = w.Load()
+		case <-resync.C:
+			_ = w.Load()
+		}
+	}
+}
+
+func (w *Watcher) watchedDirs() []string {
+	if info, err := os.Stat(w.path); err == nil && info.IsDir() {
+		return []string{w.path}
+	}
+	return []string{filepath.Dir(filepath.Clean(w.path))}
+}
+
+// HealthCheck fails while no config has loaded or the latest change to the
+// config was rejected.  It is a healthcheck.Check.
+func (w *Watcher) HealthCheck() func() error {
+	return func() error {
+		err := w.LastError()
+		current := w.Current()
+		switch {
+		case current == nil && err != nil:
+			return fmt.Errorf("no configuration loaded: %w", err)
+		case current == nil:
+			return fmt.Errorf("no configuration loaded")
+		case err != nil:
+			return fmt.Errorf("serving generation %d (%s) loaded at %s, latest configuration rejected: %w",
+				current.Generation, current.Hash, current.LoadedAt.Format(time.RFC3339), err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,120 @@
+package check_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+)
+
+// projectConfigMap lays out files the way kubelet does, swapping the `..data`
+// symlink to publish a new version
+func projectConfigMap(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+	data := filepath.Join(dir, "..v"+version)
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		writeConfig(t, data, name, content)
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join("..data", name), link); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(data), tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_KeepsLastKnownGood(t *testing.T) {
+	dir := t.TempDir()
+	projectConfigMap(t, dir, "1", map[string]string{"config.json": jsonConfig})
+
+	w := check.NewWatcher(dir)
+	if err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+	first := w.Current()
+	if first == nil || first.Generation != 1 || len(first.Specs) != 1 || first.Hash == "" {
+		t.Fatalf("first load = %+v", first)
+	}
+	if err := w.HealthCheck()(); err != nil {
+		t.Errorf("HealthCheck() = %v after a good load", err)
+	}
+
+	// Reloading the same content is not a new generation
+	if err := w.Load(); err != nil || w.Current() != first {
+		t.Errorf("reloading unchanged content should keep the snapshot, err = %v", err)
+	}
+
+	projectConfigMap(t, dir, "2", map[string]string{"config.json": "[{"})
+	if err := w.Load(); err == nil {
+		t.Fatal("Load() should reject malformed config")
+	}
+	if w.Current() != first {
+		t.Errorf("a bad config must not replace the last good one")
+	}
+	if err := w.HealthCheck()(); err == nil {
+		t.Errorf("HealthCheck() should report the rejected config")
+	}
+
+	projectConfigMap(t, dir, "3", map[string]string{"config.json": jsonConfig, "more.yaml": yamlConfig})
+	if err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.Current(); got.Generation != 2 || len(got.Specs) != 3 || got.Hash == first.Hash {
+		t.Errorf("second good load = %+v", got)
+	}
+}
+
+func TestWatcher_MissingPath(t *testing.T) {
+	w := check.NewWatcher(filepath.Join(t.TempDir(), "absent"))
+	if err := w.Load(); err != nil {
+		t.Fatalf("a missing config should load as empty, got %v", err)
+	}
+	if got := w.Current(); got == nil || len(got.Specs) != 0 {
+		t.Errorf("Current() = %+v, want an empty snapshot", got)
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+	projectConfigMap(t, dir, "1", map[string]string{"config.json": jsonConfig})
+
+	w := check.NewWatcher(dir)
+	w.Debounce = 10 * time.Millisecond
+	if err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// Give the watcher a moment to register before changing anything
+	time.Sleep(50 * time.Millisecond)
+	projectConfigMap(t, dir, "2", map[string]string{"config.json": jsonConfig, "more.yaml": yamlConfig})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Current().Generation != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher did not pick up the ConfigMap update")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,40 @@
+package check
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// ZonePlaceholder stands for the zone in the target name of a per zone check,
+// e.g. "edge-{zone}"
+const ZonePlaceholder = "{zone}"
+
+// ForZone is the check of a single zone of a per zone check.  It scales the
+// target named after the zone with the capacity of the zone's nodes only.
+func (s *Spec) ForZone(zone string) Spec {
+	zoned := *s.DeepCopy()
+	zoned.PerZone = false
+	zoned.Zone = zone
+	zoned.Target.Name = strings.ReplaceAll(s.Target.Name, ZonePlaceholder, zone)
+
+	selector := v1.LabelTopologyZone + "=" + zone
+	if zoned.Nodes.Selector != "" {
+		selector = zoned.Nodes.Selector + "," + selector
+	}
+	zoned.Nodes.Selector = selector
+	return zoned
+}
+
+// templateName is a valid name standing in for the zone while validating a
+// templated target name
+const templateName = "zone"
+
+// targetName is the target name to validate, with the placeholder of a per
+// zone check filled in
+func (s *Spec) targetName() string {
+	if !s.PerZone {
+		return s.Target.Name
+	}
+	return strings.ReplaceAll(s.Target.Name, ZonePlaceholder, templateName)
+}
@@ -0,0 +1,58 @@
+package check_test
+
+import (
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestSpec_ForZone(t *testing.T) {
+	spec := GiveMeASpec()
+	spec.PerZone = true
+	spec.Target.Name = "edge-{zone}"
+	spec.Nodes.Selector = "pool=edge"
+
+	zoned := spec.ForZone("eu-west-1a")
+	if zoned.Target.Name != "edge-eu-west-1a" || zoned.Zone != "eu-west-1a" || zoned.PerZone {
+		t.Errorf("check.Spec.ForZone() = %+v, want the target of eu-west-1a", zoned)
+	}
+	if spec.Target.Name != "edge-{zone}" || spec.Zone != "" {
+		t.Errorf("check.Spec.ForZone() changed the per zone check to %+v", spec)
+	}
+
+	inZone := GiveMeANode(map[string]string{"pool": "edge", v1.LabelTopologyZone: "eu-west-1a"})
+	otherZone := GiveMeANode(map[string]string{"pool": "edge", v1.LabelTopologyZone: "eu-west-1b"})
+	otherPool := GiveMeANode(map[string]string{"pool": "batch", v1.LabelTopologyZone: "eu-west-1a"})
+	selector, err := labels.Parse(zoned.Nodes.Selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		node *v1.Node
+		want bool
+	}{{inZone, true}, {otherZone, false}, {otherPool, false}} {
+		if got := selector.Matches(labels.Set(tt.node.Labels)); got != tt.want {
+			t.Errorf("selector %q matches %v = %v, want %v", zoned.Nodes.Selector, tt.node.Labels, got, tt.want)
+		}
+	}
+
+	if err := check.Validate([]check.Spec{spec, zoned}); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidate_PerZone(t *testing.T) {
+	spec := GiveMeASpec()
+	spec.PerZone = true
+	if verr := problemsOf(t, check.Validate([]check.Spec{spec})); len(verr) != 1 || verr[0].Field != "Target.Name" {
+		t.Errorf("Validate() = %v, want a problem with Target.Name without %s", verr, check.ZonePlaceholder)
+	}
+
+	spec.PerZone = false
+	spec.Target.Name = "edge-{zone}"
+	if verr := problemsOf(t, check.Validate([]check.Spec{spec})); len(verr) != 1 || verr[0].Field != "Target.Name" {
+		t.Errorf("Validate() = %v, want a problem with a templated Target.Name outside a per zone check", verr)
+	}
+}
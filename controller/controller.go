@@ -0,0 +1,556 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ryanmt/cluster-resource-autoscaler/aggregate"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/history"
+	"github.com/ryanmt/cluster-resource-autoscaler/logging"
+	"github.com/ryanmt/cluster-resource-autoscaler/scaler"
+	"github.com/ryanmt/cluster-resource-autoscaler/utilization"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var logger logr.Logger = logr.Discard()
+
+func Init(ctx context.Context) {
+	logger = logging.FromContextOrDiscard(ctx)
+}
+
+// CheckResult is the recommendation of a single check within a group
+type CheckResult struct {
+	Name                string
+	Source              string
+	RecommendedReplicas int32
+	// Bound is the replica bound holding RecommendedReplicas, if any
+	Bound check.Bound
+	// HeldBy names what kept RecommendedReplicas from following capacity, if
+	// anything, e.g. HeldByTolerance
+	HeldBy string
+	// Schedule names the check's active schedule, if any
+	Schedule string
+}
+
+// What can hold a recommendation back from following capacity
+const (
+	HeldByTolerance     = "tolerance"
+	HeldByStabilization = "scaleDownStabilization"
+	HeldByRateLimit     = "rateLimit"
+)
+
+// Result is what happened to a target during a tick, used to report status
+type Result struct {
+	CurrentReplicas     int32
+	RecommendedReplicas int32
+	Checks              []CheckResult
+	// HPA names the HorizontalPodAutoscaler whose minReplicas is set to
+	// RecommendedReplicas, empty when the target is scaled directly
+	HPA string
+	Err error
+}
+
+// recommendations remembers what every check recommended recently, keyed by
+// historyKey, for the scale down stabilization window
+var recommendations = history.NewStore()
+
+// capacityHistory remembers the inputs every check measured recently, keyed
+// by historyKey and input, for predictions
+var capacityHistory = history.NewStore()
+
+// replicaHistory remembers the replicas every target had recently, keyed by
+// the target, for the rate rules
+var replicaHistory = history.NewStore()
+
+// ExpandZones replaces every per zone check with a check per zone its nodes
+// are in.  A per zone check whose nodes can't be listed is skipped this tick.
+func ExpandZones(specs []check.Spec) []check.Spec {
+	var expanded []check.Spec
+	for i := range specs {
+		checkSpec := &specs[i]
+		if !checkSpec.PerZone {
+			expanded = append(expanded, *checkSpec)
+			continue
+		}
+
+		checkLogger := logger.WithValues("target", checkSpec.TargetKey(), "checkName", checkSpec.Name)
+		nodes, err := utilization.ListNodes(checkSpec.Nodes)
+		if err != nil {
+			checkLogger.Error(err, "Error listing nodes to split by zone")
+			continue
+		}
+		zones := utilization.ZoneNames(utilization.EligibleNodes(nodes, checkSpec.Eligibility, time.Now()))
+		checkLogger.V(1).Info("Splitting check by zone", "zones", zones)
+		for _, zone := range zones {
+			expanded = append(expanded, checkSpec.ForZone(zone))
+		}
+	}
+	return expanded
+}
+
+// Reconcile computes a recommendation from every check of the group and scales
+// the target to the highest of them
+func Reconcile(group check.Group) (result Result) {
+	groupLogger := logger.WithValues("target", group.Key(), "checks", group.Names())
+	groupLogger.V(2).Info("Reconciling target")
+
+	// An active schedule scales the recommendation of its check or replaces
+	// its bounds for this tick
+	now := time.Now()
+	checks := make([]check.Spec, len(group.Checks))
+	zones := make([]int, len(group.Checks))
+	for i, checkSpec := range group.Checks {
+		checkLogger := groupLogger.WithValues("checkName", checkSpec.Name)
+		key := historyKey(group, checkSpec)
+		nodes, err := checkNodes(checkLogger, checkSpec)
+		if err != nil {
+			checkLogger.Error(err, "Error listing nodes")
+			result.Err = err
+			return result
+		}
+		pods, err := checkPods(checkSpec)
+		if err != nil {
+			checkLogger.Error(err, "Error listing pods")
+			result.Err = err
+			return result
+		}
+		recommendation := recommend(checkLogger, key, checkSpec, nodes, pods)
+		var scheduleName string
+		if schedule := checkSpec.ActiveSchedule(now); schedule != nil {
+			scheduleName = schedule.String()
+			checkLogger.Info("Schedule active", "schedule", scheduleName, "calculatedReplicas", recommendation, "multiplier", schedule.Multiplier)
+			recommendation = schedule.Scale(recommendation)
+			checkSpec = checkSpec.WithSchedule(schedule)
+		}
+		checks[i], zones[i] = checkSpec, utilization.Zones(nodes)
+		rounded := checkSpec.Round(recommendation, zones[i])
+		if checkSpec.Rounding != nil {
+			checkLogger.V(1).Info("Rounded recommendation", "rounding", checkSpec.Rounding, "calculatedReplicas", recommendation, "roundedReplicas", rounded)
+		}
+		stabilized, heldBy := stabilize(checkLogger, key, checkSpec, rounded)
+		replicas, bound := checkSpec.Clamp(stabilized)
+		if bound != check.BoundNone {
+			checkLogger.Info("Recommendation held by replica bound", "bound", bound, "calculatedReplicas", recommendation, "boundedReplicas", replicas)
+		}
+		checkLogger.V(1).Info("Check recommendation", "calculatedReplicas", recommendation, "recommendedReplicas", replicas)
+
+		result.Checks = append(result.Checks, CheckResult{
+			Name:                checkSpec.Name,
+			Source:              checkSpec.Source,
+			RecommendedReplicas: replicas,
+			Bound:               bound,
+			HeldBy:              heldBy,
+			Schedule:            scheduleName,
+		})
+	}
+	result.RecommendedReplicas = highest(result.Checks)
+
+	currentReplicas, err := scaler.GetReplicas(group.Target)
+	if err != nil {
+		if errors.IsAlreadyExists(err) {
+			groupLogger.Error(err, "Target already exists", "target", group.Key())
+		} else if errors.IsNotFound(err) {
+			groupLogger.Error(err, "Target doesn't exist", "target", group.Key())
+		} else if meta.IsNoMatchError(err) || meta.IsAmbiguousError(err) {
+			groupLogger.Error(err, "Target kind can't be resolved", "target", group.Key(), "apiVersion", group.Target.APIVersion, "kind", group.Target.Kind)
+		} else if errors.IsForbidden(err) {
+			groupLogger.Error(err, "Not allowed to read the scale of the target, grant its scale subresource to the controller", "target", group.Key())
+		} else {
+			// Any other error belongs to this target alone, so it is reported
+			// in its status rather than stopping every other target
+			groupLogger.Error(err, "Error reading the scale of the target", "target", group.Key())
+		}
+		result.Err = err
+		return result
+	}
+	result.CurrentReplicas = currentReplicas
+
+	groupLogger.Info("Current scale", "replica_count", currentReplicas)
+
+	hpa, err := scaler.FindHPA(group.Target)
+	if err != nil {
+		groupLogger.Error(err, "Error finding HorizontalPodAutoscaler")
+		result.Err = err
+		return result
+	}
+	floor := group.HPA()
+	switch {
+	case floor != nil && hpa == nil:
+		result.Err = fmt.Errorf("no HorizontalPodAutoscaler scales %s", group.Key())
+		groupLogger.Error(result.Err, "Can't manage the HPA floor")
+		return result
+	case floor == nil && hpa != nil:
+		result.Err = fmt.Errorf("%s is scaled by HorizontalPodAutoscaler %s, set hpa to manage its floor instead", group.Key(), hpa.Name)
+		groupLogger.Error(result.Err, "Refusing to scale a target owned by an HPA")
+		return result
+	}
+
+	// Managing an HPA, the tolerance and rate rules apply to its floor
+	current := currentReplicas
+	if floor != nil {
+		current = scaler.HPAMinReplicas(hpa)
+		result.HPA = hpa.Name
+	}
+
+	var keep time.Duration
+	for _, checkSpec := range group.Checks {
+		if p := checkSpec.LongestPeriod(); p > keep {
+			keep = p
+		}
+	}
+	replicaHistory.Add(group.Key(), float64(current), keep)
+
+	for i, checkSpec := range checks {
+		checkLogger := groupLogger.WithValues("checkName", checkSpec.Name)
+		hold(checkLogger, group, checkSpec, zones[i], current, &result.Checks[i])
+	}
+	result.RecommendedReplicas = highest(result.Checks)
+	groupLogger.V(1).Info("Combined recommendation", "recommendedReplicas", result.RecommendedReplicas, "contributors", contributors(result))
+
+	if floor != nil {
+		manageHPA(groupLogger, hpa, floor, &result)
+		return result
+	}
+
+	if currentReplicas != result.RecommendedReplicas {
+		// Recommend we do the upgrade, and if not DRYRUN, do it
+		groupLogger.Info("Recommended scaling (based on all inputs)", "action", fmt.Sprintf("%d=>%d", currentReplicas, result.RecommendedReplicas), "contributors", contributors(result))
+
+		if _, ok := os.LookupEnv("CRA_DRYRUN"); ok {
+			return result
+		}
+
+		oldReplicas, err := scaler.UpdateReplicas(group.Target, result.RecommendedReplicas)
+		if err != nil {
+			groupLogger.Error(err, "Error in UpdateReplicas")
+			result.Err = err
+			return result
+		}
+		groupLogger.Info("Updated target", "oldReplicas", oldReplicas, "newReplicas", result.RecommendedReplicas)
+	}
+
+	return result
+}
+
+// manageHPA sets the floor of the target's HPA to the recommendation, raising
+// or setting its maxReplicas as needed
+func manageHPA(groupLogger logr.Logger, hpa *autoscalingv1.HorizontalPodAutoscaler, floor *check.HPAFloor, result *Result) {
+	// An HPA can't scale to zero
+	minReplicas := result.RecommendedReplicas
+	if minReplicas < 1 {
+		minReplicas = 1
+	}
+	maxReplicas, ok := floor.MaxReplicas(minReplicas)
+	if !ok {
+		maxReplicas = hpa.Spec.MaxReplicas
+		if maxReplicas < minReplicas {
+			groupLogger.Info("Raising HPA maxReplicas to the floor", "hpa", hpa.Name, "maxReplicas", maxReplicas, "minReplicas", minReplicas)
+			maxReplicas = minReplicas
+		}
+	}
+
+	currentMin := scaler.HPAMinReplicas(hpa)
+	if currentMin == minReplicas && hpa.Spec.MaxReplicas == maxReplicas {
+		return
+	}
+	groupLogger.Info("Recommended HPA floor (based on all inputs)", "hpa", hpa.Name, "action", fmt.Sprintf("%d=>%d", currentMin, minReplicas), "maxReplicas", maxReplicas, "contributors", contributors(*result))
+
+	if _, ok := os.LookupEnv("CRA_DRYRUN"); ok {
+		return
+	}
+
+	if err := scaler.UpdateHPA(hpa, minReplicas, maxReplicas); err != nil {
+		groupLogger.Error(err, "Error in UpdateHPA")
+		result.Err = err
+		return
+	}
+	groupLogger.Info("Updated HPA", "hpa", hpa.Name, "oldMinReplicas", currentMin, "newMinReplicas", minReplicas, "maxReplicas", maxReplicas)
+}
+
+// hold keeps a check's recommendation at the current replicas while within
+// its tolerance, and otherwise limits how far it moves by its rate rules,
+// rounding the limit toward the current replicas given the zones of the
+// check's nodes.  The replica bounds always win.
+func hold(checkLogger logr.Logger, group check.Group, checkSpec check.Spec, zones int, currentReplicas int32, c *CheckResult) {
+	desired := c.RecommendedReplicas
+	if desired == currentReplicas {
+		return
+	}
+
+	if checkSpec.Tolerates(currentReplicas, desired) {
+		if _, bound := checkSpec.Clamp(int64(currentReplicas)); bound == check.BoundNone {
+			checkLogger.V(1).Info("Recommendation within tolerance", "tolerance", checkSpec.Tolerance, "calculatedReplicas", desired, "currentReplicas", currentReplicas)
+			c.RecommendedReplicas, c.Bound, c.HeldBy = currentReplicas, check.BoundNone, HeldByTolerance
+			return
+		}
+	}
+
+	over := func(period time.Duration) history.Series {
+		return replicaHistory.Since(group.Key(), period)
+	}
+	lowest := func(period time.Duration) int32 { return int32(over(period).Min()) }
+	highest := func(period time.Duration) int32 { return int32(over(period).Max()) }
+
+	limited, reason := checkSpec.LimitRate(currentReplicas, desired, lowest, highest)
+	if reason == "" {
+		return
+	}
+	// The limit may fall between the counts the check's rounding allows
+	limited = checkSpec.RoundToward(limited, currentReplicas, zones)
+	replicas, bound := checkSpec.Clamp(int64(limited))
+	checkLogger.Info("Recommendation rate limited", "reason", reason, "calculatedReplicas", desired, "currentReplicas", currentReplicas, "limitedReplicas", replicas)
+	c.RecommendedReplicas, c.Bound = replicas, bound
+	if bound == check.BoundNone {
+		c.HeldBy = HeldByRateLimit
+	}
+}
+
+// historyKey identifies a check of a target across ticks.  Names are only
+// unique within a source, e.g. a ScalingPolicy and the config file may both
+// name a check of the same target "cpu", so the source is part of the key.
+func historyKey(group check.Group, checkSpec check.Spec) string {
+	return group.Key() + "#" + checkSpec.Source + "#" + checkSpec.Name
+}
+
+// stabilize records the calculated replicas and, with a scale down
+// stabilization window, scales down no further than the highest of the
+// recommendations over the window
+func stabilize(checkLogger logr.Logger, key string, checkSpec check.Spec, calculated int64) (int64, string) {
+	window := checkSpec.ScaleDownStabilization.Duration
+	recommendations.Add(key, float64(calculated), window)
+	if window <= 0 {
+		return calculated, ""
+	}
+
+	highest := int64(recommendations.Since(key, window).Max())
+	if highest > calculated {
+		checkLogger.V(1).Info("Scale down held by stabilization window", "window", window.String(), "calculatedReplicas", calculated, "stabilizedReplicas", highest)
+		return highest, HeldByStabilization
+	}
+	return calculated, ""
+}
+
+// highest is the largest recommendation of the checks
+func highest(checks []CheckResult) int32 {
+	var replicas int32
+	for _, c := range checks {
+		if c.RecommendedReplicas > replicas {
+			replicas = c.RecommendedReplicas
+		}
+	}
+	return replicas
+}
+
+// contributors names the checks whose recommendation won
+func contributors(result Result) []string {
+	var names []string
+	for _, c := range result.Checks {
+		if c.RecommendedReplicas == result.RecommendedReplicas {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// recommend calculates the replica count wanted by a single check from its
+// nodes and, with requests capacity, their pods, using the strategy of the
+// check's mode
+func recommend(checkLogger logr.Logger, key string, checkSpec check.Spec, nodes []corev1.Node, pods []corev1.Pod) float64 {
+	checkLogger.V(2).Info("checkSpec received")
+	if !checkSpec.Nodes.IsZero() {
+		checkLogger = checkLogger.WithValues("nodes", checkSpec.Nodes)
+	}
+
+	if checkSpec.Mode == check.ModeLadder {
+		return recommendLadder(checkLogger, key, checkSpec, nodes, pods)
+	}
+	return recommendLinear(checkLogger, key, checkSpec, nodes, pods)
+}
+
+// recommendLadder picks the replicas of the highest step reached by the
+// ladder's input
+func recommendLadder(checkLogger logr.Logger, key string, checkSpec check.Spec, nodes []corev1.Node, pods []corev1.Pod) float64 {
+	ladder := checkSpec.Ladder
+
+	var value resource.Quantity
+	if ladder.IsNodes() {
+		value = *resource.NewQuantity(int64(len(nodes)), resource.DecimalSI)
+	} else {
+		value = capacity(checkLogger, checkSpec, ladder.Resource(), nodes, pods)
+	}
+	value = predict(checkLogger, key, checkSpec, corev1.ResourceName(ladder.Input), value)
+
+	replicas := ladder.Replicas(value)
+	checkLogger.V(2).Info("Ladder step", "input", ladder.Input, "value", value.String(), "calculatedReplicas", replicas)
+	return float64(replicas)
+}
+
+// recommendLinear wants one replica per PerReplica amount of each resource,
+// combining the per-resource recommendations with the check's aggregation.
+// Fractions of a replica are kept for the check's rounding.
+func recommendLinear(checkLogger logr.Logger, key string, checkSpec check.Spec, nodes []corev1.Node, pods []corev1.Pod) float64 {
+	var recommendations []aggregate.Value
+
+	for _, rName := range checkSpec.Resources() {
+		perReplica := checkSpec.ResourceScaler(rName)
+		checkLogger.V(1).Info("scaleFactor calculation", "resource", rName, "scaleFactor", perReplica.String())
+		if !perReplica.IsZero() {
+			scalerLogger := checkLogger.WithValues("resource", rName)
+			availableResource := capacity(scalerLogger, checkSpec, rName, nodes, pods)
+			availableResource = predict(scalerLogger, key, checkSpec, rName, availableResource)
+			// Utilization is only logged, so metrics are only read to log it
+			if scalerLogger.V(2).Enabled() {
+				percentage := utilization.PercentageByResource(rName, nodes)
+				usagePct := fmt.Sprintf("%.2f", percentage*100.0)
+				scalerLogger.V(2).Info("Percent utilization", "usage_pct", usagePct, "per_replica", perReplica.String())
+			}
+
+			newRecommendation := utilization.Quotient(availableResource, perReplica)
+			scalerLogger.V(2).Info("Scaling quotient", "available", availableResource.String(), "scaler", perReplica.String(), "calculatedReplicas", newRecommendation)
+
+			recommendations = append(recommendations, aggregate.Value{
+				Name:     string(rName),
+				Replicas: newRecommendation,
+				Weight:   checkSpec.Weight(rName),
+			})
+		} else {
+			checkLogger.V(1).Info("Scaler does not apply", "resource", rName)
+		}
+	}
+
+	if !checkSpec.NodesPerReplica.IsZero() || !checkSpec.SchedulablePodsPerReplica.IsZero() {
+		inputs := []struct {
+			name       corev1.ResourceName
+			value      resource.Quantity
+			perReplica resource.Quantity
+		}{
+			{check.InputNodes, *resource.NewQuantity(int64(len(nodes)), resource.DecimalSI), checkSpec.NodesPerReplica},
+			{check.InputSchedulablePods, utilization.SumAllocatable(nodes, corev1.ResourcePods), checkSpec.SchedulablePodsPerReplica},
+		}
+		for _, input := range inputs {
+			if input.perReplica.IsZero() {
+				continue
+			}
+			value := predict(checkLogger, key, checkSpec, input.name, input.value)
+			newRecommendation := utilization.Quotient(value, input.perReplica)
+			checkLogger.V(2).Info("Scaling quotient", "input", input.name, "available", value.String(), "scaler", input.perReplica.String(), "calculatedReplicas", newRecommendation)
+
+			recommendations = append(recommendations, aggregate.Value{
+				Name:     string(input.name),
+				Replicas: newRecommendation,
+				Weight:   checkSpec.Weight(input.name),
+			})
+		}
+	}
+
+	strategy, _ := aggregate.Parse(string(checkSpec.Aggregation))
+	recommendedReplicas := aggregate.Combine(strategy, recommendations)
+	checkLogger.V(2).Info("Aggregated resource recommendations", "aggregation", strategy, "calculatedReplicas", recommendedReplicas)
+
+	return recommendedReplicas
+}
+
+// predict records a measured input of a check and, with a prediction, scales
+// for the higher of the measurement and its trend forecast a lead time ahead.
+// Falling trends are left to the scale down stabilization.
+func predict(checkLogger logr.Logger, key string, checkSpec check.Spec, input corev1.ResourceName, measured resource.Quantity) resource.Quantity {
+	inputKey := key + "#" + string(input)
+	window := checkSpec.PredictionWindow()
+	capacityHistory.Add(inputKey, measured.AsApproximateFloat64(), window)
+	if window <= 0 {
+		return measured
+	}
+
+	series := capacityHistory.Since(inputKey, window)
+	if len(series) < check.MinPredictionSamples {
+		checkLogger.V(1).Info("Too little history to predict", "input", input, "samples", len(series), "minSamples", check.MinPredictionSamples)
+		return measured
+	}
+	leadTime := checkSpec.Prediction.LeadTime.Duration
+	forecast, ok := series.Forecast(capacityHistory.Now().Add(leadTime))
+	if !ok || math.IsNaN(forecast) || forecast <= measured.AsApproximateFloat64() {
+		return measured
+	}
+
+	predicted := approximateQuantity(forecast, measured.Format)
+	checkLogger.V(1).Info("Scaling for predicted capacity", "input", input, "measured", measured.String(), "predicted", predicted.String(), "leadTime", leadTime.String())
+	return predicted
+}
+
+// approximateQuantity rounds a value up to a quantity, to the milli unit
+// where that fits.  No capacity is negative, so neither is the quantity.
+func approximateQuantity(value float64, format resource.Format) resource.Quantity {
+	switch {
+	case math.IsNaN(value) || value <= 0:
+		return *resource.NewQuantity(0, format)
+	case value >= math.MaxInt64:
+		return *resource.NewQuantity(math.MaxInt64, format)
+	case value >= math.MaxInt64/1000:
+		return *resource.NewQuantity(int64(math.Ceil(value)), format)
+	}
+	return *resource.NewMilliQuantity(int64(math.Ceil(value*1000)), format)
+}
+
+// capacity measures a resource of the nodes from the check's capacity source,
+// summing requests from the pods listed by checkPods
+func capacity(checkLogger logr.Logger, checkSpec check.Spec, rName corev1.ResourceName, nodes []corev1.Node, pods []corev1.Pod) resource.Quantity {
+	switch checkSpec.CapacitySource() {
+	case check.CapacityUsage:
+		usage, ok := utilization.UsageByResource(rName, nodes, checkSpec.MaxMetricsAge())
+		if ok {
+			checkLogger.V(2).Info("Capacity from node usage", "resource", rName, "value", usage.String())
+			return usage
+		}
+		checkLogger.Info("Node metrics missing or stale, falling back to allocatable", "resource", rName, "maxAge", checkSpec.MaxMetricsAge().String())
+	case check.CapacityRequests:
+		requested := utilization.SumRequests(pods, nodes, rName)
+		checkLogger.V(2).Info("Capacity from pod requests", "resource", rName, "pods", len(pods), "value", requested.String())
+		return requested
+	}
+	return utilization.CapacityByResource(rName, nodes)
+}
+
+// checkPods lists the pods whose requests count for a check with requests
+// capacity, nil for any other check
+func checkPods(checkSpec check.Spec) ([]corev1.Pod, error) {
+	if checkSpec.CapacitySource() != check.CapacityRequests {
+		return nil, nil
+	}
+	pods, err := utilization.ListPods(checkSpec.Pods)
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+	return pods, nil
+}
+
+// checkNodes lists the nodes whose capacity counts for the check
+func checkNodes(checkLogger logr.Logger, checkSpec check.Spec) ([]corev1.Node, error) {
+	listed, err := utilization.ListNodes(checkSpec.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	nodes := utilization.EligibleNodes(listed, checkSpec.Eligibility, time.Now())
+	if len(nodes) < len(listed) {
+		checkLogger.V(1).Info("Ignoring ineligible nodes", "eligible", len(nodes), "nodes", len(listed))
+	}
+	if !checkSpec.Nodes.MatchTarget {
+		return nodes, nil
+	}
+
+	template, err := scaler.PodTemplate(checkSpec.Target)
+	if err != nil {
+		return nil, fmt.Errorf("reading pod template of %s: %w", checkSpec.TargetKey(), err)
+	}
+	schedulable := utilization.SchedulableNodes(nodes, &template.Spec)
+	checkLogger.V(2).Info("Nodes the target can be scheduled onto", "schedulable", len(schedulable), "nodes", len(nodes))
+	return schedulable, nil
+}
@@ -0,0 +1,342 @@
+package controller_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/ryanmt/cluster-resource-autoscaler/aggregate"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/controller"
+	"github.com/ryanmt/cluster-resource-autoscaler/history"
+	"github.com/ryanmt/cluster-resource-autoscaler/utilization"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GiveMeAClock resets the history of every check, returning a function moving
+// its clock forward along with the replica history
+func GiveMeAClock() (func(time.Duration), *history.Store) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	_, _, replicaHistory := controller.ResetHistory(func() time.Time { return now })
+	return func(d time.Duration) { now = now.Add(d) }, replicaHistory
+}
+
+// GiveMeAGroup returns a group of the check, along with the check
+func GiveMeAGroup(checkSpec check.Spec) (check.Group, check.Spec) {
+	checkSpec.Name = "cpu"
+	checkSpec.Target = check.ScalingTarget{Kind: "deployment", Name: "name", Namespace: "default"}
+	return check.Group{Target: checkSpec.Target, Checks: []check.Spec{checkSpec}}, checkSpec
+}
+
+func replicas(n int32) *int32 { return &n }
+
+func TestStabilize(t *testing.T) {
+	window := metav1.Duration{Duration: 5 * time.Minute}
+
+	// Each recommendation is calculated a minute after the one before it,
+	// unless its step says otherwise
+	type step struct {
+		after      time.Duration
+		calculated int64
+	}
+	tests := []struct {
+		name       string
+		window     metav1.Duration
+		steps      []step
+		want       int64
+		wantHeldBy string
+	}{
+		{"without a window", metav1.Duration{}, []step{{0, 10}, {time.Minute, 4}}, 4, ""},
+		{"scale up", window, []step{{0, 4}, {time.Minute, 10}}, 10, ""},
+		{"scale down within the window", window, []step{{0, 10}, {time.Minute, 6}, {time.Minute, 4}}, 10, controller.HeldByStabilization},
+		{"highest within the window", window, []step{{0, 10}, {3 * time.Minute, 8}, {3 * time.Minute, 4}}, 8, controller.HeldByStabilization},
+		{"just within the window", window, []step{{0, 10}, {window.Duration - time.Second, 4}}, 10, controller.HeldByStabilization},
+		{"a full window ago", window, []step{{0, 10}, {window.Duration, 4}}, 4, ""},
+		{"long after the window", window, []step{{0, 10}, {time.Hour, 4}}, 4, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance, _ := GiveMeAClock()
+			group, checkSpec := GiveMeAGroup(check.Spec{ScaleDownStabilization: tt.window})
+			key := controller.HistoryKey(group, checkSpec)
+
+			var got int64
+			var heldBy string
+			for _, s := range tt.steps {
+				advance(s.after)
+				got, heldBy = controller.Stabilize(logr.Discard(), key, checkSpec, s.calculated)
+			}
+			if got != tt.want || heldBy != tt.wantHeldBy {
+				t.Errorf("stabilize() = %v, %q, want %v, %q", got, heldBy, tt.want, tt.wantHeldBy)
+			}
+		})
+	}
+}
+
+func TestStabilize_KeyedBySource(t *testing.T) {
+	GiveMeAClock()
+	group, fromFile := GiveMeAGroup(check.Spec{ScaleDownStabilization: metav1.Duration{Duration: time.Minute}})
+	fromFile.Source = "config.json"
+	fromPolicy := fromFile
+	fromPolicy.Source = "ScalingPolicy/default/name"
+
+	controller.Stabilize(logr.Discard(), controller.HistoryKey(group, fromFile), fromFile, 10)
+	if got, _ := controller.Stabilize(logr.Discard(), controller.HistoryKey(group, fromPolicy), fromPolicy, 4); got != 4 {
+		t.Errorf("stabilize() = %v, a check of the same name from another source shouldn't hold it", got)
+	}
+}
+
+func TestHold_Tolerance(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       check.Spec
+		current    int32
+		desired    int32
+		want       int32
+		wantHeldBy string
+	}{
+		{"within tolerance", check.Spec{Tolerance: 0.2}, 10, 11, 10, controller.HeldByTolerance},
+		{"exactly at tolerance", check.Spec{Tolerance: 0.1}, 10, 11, 10, controller.HeldByTolerance},
+		{"beyond tolerance", check.Spec{Tolerance: 0.1}, 10, 12, 12, ""},
+		{"without tolerance", check.Spec{}, 10, 11, 11, ""},
+		{"from zero", check.Spec{Tolerance: 0.5}, 0, 1, 1, ""},
+		{"current beyond the bounds", check.Spec{Tolerance: 0.2, MaxReplicas: replicas(9)}, 10, 9, 9, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			GiveMeAClock()
+			group, checkSpec := GiveMeAGroup(tt.spec)
+			c := controller.CheckResult{RecommendedReplicas: tt.desired}
+			controller.Hold(logr.Discard(), group, checkSpec, 1, tt.current, &c)
+			if c.RecommendedReplicas != tt.want || c.HeldBy != tt.wantHeldBy {
+				t.Errorf("hold() = %v, %q, want %v, %q", c.RecommendedReplicas, c.HeldBy, tt.want, tt.wantHeldBy)
+			}
+		})
+	}
+}
+
+func TestHold_RateLimit(t *testing.T) {
+	minute := metav1.Duration{Duration: time.Minute}
+	pods := func(n int32) *check.RateRules {
+		return &check.RateRules{Policies: []check.RatePolicy{{Type: check.PodsRatePolicy, Value: n, Period: minute}}}
+	}
+	percent := func(n int32) *check.RateRules {
+		return &check.RateRules{Policies: []check.RatePolicy{{Type: check.PercentRatePolicy, Value: n, Period: minute}}}
+	}
+
+	// Replicas the target had, a step apart, the last one being current
+	type step struct {
+		after    time.Duration
+		replicas int32
+	}
+	tests := []struct {
+		name       string
+		spec       check.Spec
+		history    []step
+		desired    int32
+		want       int32
+		wantHeldBy string
+	}{
+		{"unlimited", check.Spec{}, []step{{0, 10}}, 20, 20, ""},
+		{"below the limit", check.Spec{ScaleUp: pods(4)}, []step{{0, 10}}, 13, 13, ""},
+		{"exactly the limit", check.Spec{ScaleUp: pods(4)}, []step{{0, 10}}, 14, 14, ""},
+		{"beyond the limit", check.Spec{ScaleUp: pods(4)}, []step{{0, 10}}, 15, 14, controller.HeldByRateLimit},
+		{"limit used up within the period", check.Spec{ScaleUp: pods(4)}, []step{{0, 10}, {30 * time.Second, 14}}, 20, 14, controller.HeldByRateLimit},
+		{"limit just within the period", check.Spec{ScaleUp: pods(4)}, []step{{0, 10}, {time.Minute - time.Second, 14}}, 20, 14, controller.HeldByRateLimit},
+		{"limit renewed a full period later", check.Spec{ScaleUp: pods(4)}, []step{{0, 10}, {time.Minute, 14}}, 20, 18, controller.HeldByRateLimit},
+		{"scale down exactly the limit", check.Spec{ScaleDown: percent(50)}, []step{{0, 10}}, 5, 5, ""},
+		{"scale down beyond the limit", check.Spec{ScaleDown: percent(50)}, []step{{0, 10}}, 4, 5, controller.HeldByRateLimit},
+		{"scale down from the highest within the period", check.Spec{ScaleDown: percent(50)}, []step{{0, 20}, {30 * time.Second, 12}}, 4, 10, controller.HeldByRateLimit},
+		{"disabled", check.Spec{ScaleDown: &check.RateRules{SelectPolicy: check.SelectDisabled}}, []step{{0, 10}}, 4, 10, controller.HeldByRateLimit},
+		{"limit below the bounds", check.Spec{ScaleDown: pods(1), MaxReplicas: replicas(8)}, []step{{0, 10}}, 4, 8, ""},
+		{"limit rounded down to a multiple", check.Spec{ScaleUp: pods(4), Rounding: &check.Rounding{MultipleOf: 3}}, []step{{0, 3}}, 30, 6, controller.HeldByRateLimit},
+		{"limit rounded down to an odd count", check.Spec{ScaleUp: pods(3), Rounding: &check.Rounding{Odd: true}}, []step{{0, 5}}, 15, 7, controller.HeldByRateLimit},
+		{"scale down limit rounded up to a multiple", check.Spec{ScaleDown: pods(4), Rounding: &check.Rounding{MultipleOf: 3}}, []step{{0, 12}}, 3, 9, controller.HeldByRateLimit},
+		{"no allowed count within the limit", check.Spec{ScaleUp: pods(2), Rounding: &check.Rounding{MultipleOf: 4}}, []step{{0, 4}}, 12, 4, controller.HeldByRateLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance, replicaHistory := GiveMeAClock()
+			group, checkSpec := GiveMeAGroup(tt.spec)
+
+			// Replicas are recorded each tick before holding, as in Reconcile
+			var current int32
+			for _, s := range tt.history {
+				advance(s.after)
+				current = s.replicas
+				replicaHistory.Add(group.Key(), float64(current), checkSpec.LongestPeriod())
+			}
+			c := controller.CheckResult{RecommendedReplicas: tt.desired}
+			controller.Hold(logr.Discard(), group, checkSpec, 1, current, &c)
+			if c.RecommendedReplicas != tt.want || c.HeldBy != tt.wantHeldBy {
+				t.Errorf("hold() = %v, %q, want %v, %q", c.RecommendedReplicas, c.HeldBy, tt.want, tt.wantHeldBy)
+			}
+		})
+	}
+}
+
+func TestPredict(t *testing.T) {
+	prediction := &check.Prediction{
+		Window:   metav1.Duration{Duration: 10 * time.Minute},
+		LeadTime: metav1.Duration{Duration: 5 * time.Minute},
+	}
+
+	// Capacity is measured once every so often, the last measurement being
+	// current
+	tests := []struct {
+		name       string
+		prediction *check.Prediction
+		every      time.Duration
+		measured   []string
+		want       string
+	}{
+		{"without a prediction", nil, time.Minute, []string{"10", "20", "30"}, "30"},
+		{"a single sample", prediction, time.Minute, []string{"10"}, "10"},
+		{"too few samples", prediction, time.Minute, []string{"10", "20"}, "20"},
+		{"samples taken at once", prediction, 0, []string{"10", "20", "30"}, "30"},
+		{"rising", prediction, time.Minute, []string{"10", "20", "30"}, "80"},
+		{"rising by fractions", prediction, time.Minute, []string{"1", "1500m", "2"}, "4500m"},
+		{"flat", prediction, time.Minute, []string{"10", "10", "10"}, "10"},
+		{"falling", prediction, time.Minute, []string{"30", "20", "10"}, "10"},
+		{"falling below zero", prediction, time.Minute, []string{"100", "50", "1"}, "1"},
+		{"rising beyond any quantity", prediction, time.Minute, []string{"1e18", "4e18", "7e18"}, "9223372036854775807"},
+		{"older samples outside the window", prediction, 4 * time.Minute, []string{"1000", "10", "20", "30"}, "42500m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance, _ := GiveMeAClock()
+			group, checkSpec := GiveMeAGroup(check.Spec{Prediction: tt.prediction})
+			key := controller.HistoryKey(group, checkSpec)
+
+			var got resource.Quantity
+			for _, m := range tt.measured {
+				got = controller.Predict(logr.Discard(), key, checkSpec, corev1.ResourceCPU, resource.MustParse(m))
+				advance(tt.every)
+			}
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("predict() = %v, want %v", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestApproximateQuantity(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float64
+		want  string
+	}{
+		{"zero", 0, "0"},
+		{"whole", 3, "3"},
+		{"milli units rounded up", 1.0001, "1001m"},
+		{"too large for milli units", 1e16, "1e16"},
+		{"too large for any quantity", 1e19, "9223372036854775807"},
+		{"infinite", math.Inf(1), "9223372036854775807"},
+		{"negative", -2.5, "0"},
+		{"not a number", math.NaN(), "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := controller.ApproximateQuantity(tt.value, resource.DecimalSI)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("approximateQuantity(%v) = %v, want %v", tt.value, got.String(), want.String())
+			}
+		})
+	}
+}
+
+// GiveMeNodes returns ready nodes with the allocatable cpu of each
+func GiveMeNodes(cpus ...string) []corev1.Node {
+	var nodes []corev1.Node
+	for i, cpu := range cpus {
+		nodes = append(nodes, corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)},
+			Status: corev1.NodeStatus{
+				Allocatable: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse("16Gi")},
+				Conditions:  []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		})
+	}
+	return nodes
+}
+
+func TestRecommend_Rounding(t *testing.T) {
+	tests := []struct {
+		name        string
+		rounding    *check.Rounding
+		aggregation aggregate.Strategy
+		memory      string
+		want        int64
+	}{
+		{"rounds up by default", nil, "", "", 5},
+		{"floor", &check.Rounding{Mode: check.RoundFloor}, "", "", 4},
+		{"nearest", &check.Rounding{Mode: check.RoundNearest}, "", "", 4},
+		{"floor to a multiple", &check.Rounding{Mode: check.RoundFloor, MultipleOf: 2}, "", "", 4},
+		{"floor of the highest fraction", &check.Rounding{Mode: check.RoundFloor}, aggregate.Max, "5Gi", 6},
+		// 4.2 and 6.4 replicas average 5.3, where rounding each up first gives 6
+		{"nearest of the mean", &check.Rounding{Mode: check.RoundNearest}, aggregate.Mean, "5Gi", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			GiveMeAClock()
+			group, checkSpec := GiveMeAGroup(check.Spec{
+				CPUPerReplica: resource.MustParse("1"),
+				Rounding:      tt.rounding,
+				Aggregation:   tt.aggregation,
+			})
+			if tt.memory != "" {
+				checkSpec.MemoryPerReplica = resource.MustParse(tt.memory)
+			}
+			nodes := GiveMeNodes("2100m", "2100m")
+
+			recommendation := controller.Recommend(logr.Discard(), controller.HistoryKey(group, checkSpec), checkSpec, nodes, nil)
+			if got := checkSpec.Round(recommendation, 1); got != tt.want {
+				t.Errorf("Round(recommend()) = %v from %v, want %v", got, recommendation, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecommend_EligibleNodes(t *testing.T) {
+	now := time.Now()
+	nodes := GiveMeNodes("4", "4", "4", "4")
+	// Within the grace period, so still counting
+	nodes[1].Status.Conditions[0] = corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionFalse, LastTransitionTime: metav1.NewTime(now.Add(-time.Minute))}
+	// Past the grace period
+	nodes[2].Status.Conditions[0] = corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionFalse, LastTransitionTime: metav1.NewTime(now.Add(-time.Hour))}
+	nodes[3].Spec.Unschedulable = true
+	for i := range nodes {
+		nodes[i].Status.Allocatable[corev1.ResourcePods] = resource.MustParse("10")
+	}
+
+	tests := []struct {
+		name string
+		spec check.Spec
+		want float64
+	}{
+		{"nodes", check.Spec{NodesPerReplica: resource.MustParse("1")}, 2},
+		{"schedulable pods", check.Spec{SchedulablePodsPerReplica: resource.MustParse("10")}, 2},
+		{"ladder", check.Spec{Mode: check.ModeLadder, Ladder: &check.Ladder{Input: string(check.InputNodes), Steps: []check.Step{
+			{Threshold: resource.MustParse("1"), Replicas: 1},
+			{Threshold: resource.MustParse("2"), Replicas: 5},
+			{Threshold: resource.MustParse("3"), Replicas: 9},
+		}}}, 5},
+		{"cordoned nodes counting", check.Spec{NodesPerReplica: resource.MustParse("1"), Eligibility: check.Eligibility{IncludeUnschedulable: true}}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			GiveMeAClock()
+			tt.spec.Eligibility.NotReadyGracePeriod = metav1.Duration{Duration: 5 * time.Minute}
+			group, checkSpec := GiveMeAGroup(tt.spec)
+			eligible := utilization.EligibleNodes(nodes, checkSpec.Eligibility, now)
+
+			if got := controller.Recommend(logr.Discard(), controller.HistoryKey(group, checkSpec), checkSpec, eligible, nil); got != tt.want {
+				t.Errorf("Recommend() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
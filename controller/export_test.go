@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/history"
+)
+
+// The pure parts of a reconcile, exported for tests
+var (
+	Recommend           = recommend
+	Hold                = hold
+	HistoryKey          = historyKey
+	Stabilize           = stabilize
+	Predict             = predict
+	ApproximateQuantity = approximateQuantity
+)
+
+// ResetHistory replaces every history with an empty one taking samples with
+// the clock now, returning the recommendations, capacity and replica histories
+func ResetHistory(now func() time.Time) (*history.Store, *history.Store, *history.Store) {
+	for _, store := range []**history.Store{&recommendations, &capacityHistory, &replicaHistory} {
+		*store = history.NewStore()
+		(*store).Now = now
+	}
+	return recommendations, capacityHistory, replicaHistory
+}
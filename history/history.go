@@ -0,0 +1,132 @@
+package history
+
+import (
+	"sync"
+	"time"
+)
+
+// Sample is a value observed at a point in time
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is a time ordered list of samples
+type Series []Sample
+
+// Max is the highest value of the series, zero when it is empty
+func (s Series) Max() float64 {
+	var max float64
+	for i, sample := range s {
+		if i == 0 || sample.Value > max {
+			max = sample.Value
+		}
+	}
+	return max
+}
+
+// Min is the lowest value of the series, zero when it is empty
+func (s Series) Min() float64 {
+	var min float64
+	for i, sample := range s {
+		if i == 0 || sample.Value < min {
+			min = sample.Value
+		}
+	}
+	return min
+}
+
+// Forecast extrapolates the least squares linear trend of the series to a
+// point in time.  ok is false without two samples taken at different times.
+func (s Series) Forecast(at time.Time) (value float64, ok bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+
+	// Seconds are counted from the first sample to keep the sums small
+	origin := s[0].Time
+	n := float64(len(s))
+	var sumX, sumY, sumXX, sumXY float64
+	for _, sample := range s {
+		x := sample.Time.Sub(origin).Seconds()
+		sumX += x
+		sumY += sample.Value
+		sumXX += x * x
+		sumXY += x * sample.Value
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+	return intercept + slope*at.Sub(origin).Seconds(), true
+}
+
+// Store keeps recent samples of many series in memory, e.g. the
+// recommendations of every check.  Nothing survives a restart.
+type Store struct {
+	mu     sync.Mutex
+	series map[string]entry
+
+	// Now is the clock samples are taken with, replaceable in tests
+	Now func() time.Time
+}
+
+// entry is the series of a key along with how long its samples are kept
+type entry struct {
+	series Series
+	keep   time.Duration
+}
+
+// NewStore creates an empty store using the wall clock
+func NewStore() *Store {
+	return &Store{series: make(map[string]entry), Now: time.Now}
+}
+
+// Add records a value for key at the current time, forgetting the samples of
+// key taken keep or longer ago.  The latest sample is always kept, until a
+// later Add of any key finds every sample of key has been kept long enough.
+// Keys of deleted checks or targets are dropped that way.
+func (s *Store) Add(key string, value float64, keep time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	for other, e := range s.series {
+		if other != key && !e.series[len(e.series)-1].Time.After(now.Add(-e.keep)) {
+			delete(s.series, other)
+		}
+	}
+
+	series := append(s.series[key].series, Sample{Time: now, Value: value})
+	cutoff := now.Add(-keep)
+	first := 0
+	for first < len(series)-1 && !series[first].Time.After(cutoff) {
+		first++
+	}
+	s.series[key] = entry{series: append(Series(nil), series[first:]...), keep: keep}
+}
+
+// Len is the number of keys with samples
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.series)
+}
+
+// Since returns a copy of the samples of key taken within window of now
+func (s *Store) Since(key string, window time.Duration) Series {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cutoff := s.Now().Add(-window)
+	var since Series
+	for _, sample := range s.series[key].series {
+		if !sample.Time.Before(cutoff) {
+			since = append(since, sample)
+		}
+	}
+	return since
+}
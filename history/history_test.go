@@ -0,0 +1,125 @@
+package history_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/history"
+)
+
+// GiveMeAStore returns a store along with a function moving its clock forward
+func GiveMeAStore() (*history.Store, func(time.Duration)) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	store := history.NewStore()
+	store.Now = func() time.Time { return now }
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestStore_Since(t *testing.T) {
+	store, advance := GiveMeAStore()
+	for _, v := range []float64{10, 8, 4, 6} {
+		store.Add("target", v, 5*time.Minute)
+		advance(time.Minute)
+	}
+
+	tests := []struct {
+		name    string
+		window  time.Duration
+		wantLen int
+		wantMax float64
+		wantMin float64
+	}{
+		{"everything", time.Hour, 4, 10, 4},
+		{"latest two", 2 * time.Minute, 2, 6, 4},
+		{"empty window", 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := store.Since("target", tt.window)
+			if len(got) != tt.wantLen || got.Max() != tt.wantMax || got.Min() != tt.wantMin {
+				t.Errorf("Since() = %v samples within [%v, %v], want %v within [%v, %v]", len(got), got.Min(), got.Max(), tt.wantLen, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+
+	if got := store.Since("other", time.Hour); len(got) != 0 {
+		t.Errorf("Since() of an unknown key = %v, want nothing", got)
+	}
+}
+
+func TestStore_Add_Forgets(t *testing.T) {
+	store, advance := GiveMeAStore()
+	store.Add("target", 10, 2*time.Minute)
+	advance(3 * time.Minute)
+	store.Add("target", 4, 2*time.Minute)
+
+	if got := store.Since("target", time.Hour); len(got) != 1 || got.Max() != 4 {
+		t.Errorf("Add() should forget samples older than keep, got %v", got)
+	}
+
+	// Without anything to keep, the latest sample still is
+	store.Add("target", 3, 0)
+	if got := store.Since("target", time.Hour); len(got) != 1 || got.Max() != 3 {
+		t.Errorf("Add() should keep the latest sample, got %v", got)
+	}
+}
+
+func TestStore_Add_DropsKeys(t *testing.T) {
+	store, advance := GiveMeAStore()
+	store.Add("deleted", 10, 2*time.Minute)
+	store.Add("unkept", 10, 0)
+	store.Add("kept", 10, 2*time.Minute)
+
+	advance(time.Minute)
+	store.Add("kept", 8, 2*time.Minute)
+	if got := store.Len(); got != 2 {
+		t.Errorf("Len() = %v, want only the key without anything to keep dropped", got)
+	}
+	if got := store.Since("deleted", time.Hour); len(got) != 1 {
+		t.Errorf("Add() should keep samples of other keys within keep, got %v", got)
+	}
+
+	advance(time.Minute)
+	store.Add("kept", 6, 2*time.Minute)
+	if got := store.Since("deleted", time.Hour); len(got) != 0 || store.Len() != 1 {
+		t.Errorf("Add() should drop keys whose latest sample is keep old, got %v of %v keys", got, store.Len())
+	}
+	if got := store.Since("kept", time.Hour); len(got) != 2 {
+		t.Errorf("Since() = %v, want the samples within keep", got)
+	}
+}
+
+func TestSeries_Forecast(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+		ok     bool
+	}{
+		{"empty", nil, 0, false},
+		{"single sample", []float64{10}, 0, false},
+		{"flat", []float64{10, 10, 10}, 10, true},
+		{"rising", []float64{10, 12, 14, 16}, 26, true},
+		{"falling", []float64{16, 14, 12, 10}, 0, true},
+		{"noisy", []float64{10, 14, 12, 16}, 23.4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, advance := GiveMeAStore()
+			for i, v := range tt.values {
+				if i > 0 {
+					advance(time.Minute)
+				}
+				store.Add("target", v, time.Hour)
+			}
+
+			// Five minutes after the last sample
+			advance(5 * time.Minute)
+			got, ok := store.Since("target", time.Hour).Forecast(store.Now())
+			if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Forecast() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
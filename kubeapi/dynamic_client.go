@@ -0,0 +1,11 @@
+package kubeapi
+
+import "k8s.io/client-go/dynamic"
+
+var dynamicClient dynamic.Interface
+
+// DynamicClient is the client for resources without typed clients, such as
+// ScalingPolicies, built once by Init
+func DynamicClient() dynamic.Interface {
+	return dynamicClient
+}
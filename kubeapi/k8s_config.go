@@ -4,6 +4,7 @@ import (
 	"context"
 	"path/filepath"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
@@ -27,4 +28,6 @@ func Init(initCtx context.Context, isDev bool) {
 			panic(err.Error())
 		}
 	}
+
+	dynamicClient = dynamic.NewForConfigOrDie(Config)
 }
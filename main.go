@@ -4,21 +4,27 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"time"
+	// Schedules name time zones, which the image doesn't carry
+	_ "time/tzdata"
 
+	"github.com/go-logr/logr"
 	"github.com/heptiolabs/healthcheck"
+	"github.com/ryanmt/cluster-resource-autoscaler/annotation"
+	"github.com/ryanmt/cluster-resource-autoscaler/api/v1alpha1"
 	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/controller"
 	"github.com/ryanmt/cluster-resource-autoscaler/health"
 	"github.com/ryanmt/cluster-resource-autoscaler/kubeapi"
 	"github.com/ryanmt/cluster-resource-autoscaler/logging"
+	"github.com/ryanmt/cluster-resource-autoscaler/policy"
 	"github.com/ryanmt/cluster-resource-autoscaler/scaler"
 	"github.com/ryanmt/cluster-resource-autoscaler/utilization"
-	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const healthCheckPort = ":8085"
@@ -43,13 +49,41 @@ func main() {
 	// Initialize generic api clients
 	kubeapi.Init(ctx, isDev)
 
+	if v, ok := os.LookupEnv("CRA_DUPLICATE_TARGETS"); ok {
+		duplicates, err := check.ParseDuplicatePolicy(v)
+		if err != nil {
+			logger.Error(err, "invalid CRA_DUPLICATE_TARGETS")
+			panic(err.Error())
+		}
+		check.Duplicates = duplicates
+	}
+
 	// Init packages to make them logging empowered or create clients if needed
 	utilization.Init(ctx)
 	check.Init(ctx)
+	policy.Init(ctx)
+	annotation.Init(ctx)
 	scaler.Init(ctx)
+	controller.Init(ctx)
 
 	logger.V(2).Info("Running...", "namespace", defaultNamespace)
 
+	// TODO: Make this configurable
+	configPath := "./config"
+	if isDev {
+		configPath = "./test_config.json"
+	}
+
+	// The mounted config directory is still honoured, but no longer required.
+	// A broken config is logged and the last good one is kept.
+	configWatcher := check.NewWatcher(configPath)
+	_ = configWatcher.Load()
+	go func() {
+		if err := configWatcher.Run(ctx); err != nil {
+			logger.Error(err, "Configuration watcher stopped")
+		}
+	}()
+
 	if isDev {
 		logger.Info("Not running health check... Dev mode")
 	} else {
@@ -66,6 +100,7 @@ func main() {
 		logger.Info("Cluster testing URL", "url", checkURL.String())
 		// healthHandler.AddReadinessCheck("cluster-connectivity", healthcheck.HTTPGetCheck(checkURL.String(), 11*time.Second))
 		healthHandler.AddReadinessCheck("GC-timing", health.GCMaxPauseCheck(1*time.Second))
+		healthHandler.AddReadinessCheck("config", configWatcher.HealthCheck())
 
 		go func() {
 			// err := http.ListenAndServe(healthCheckPort, logging.LoggingMiddleware(healthHandler, logger))
@@ -101,87 +136,54 @@ func main() {
 	}
 
 	for {
-		var config []check.Spec
-		var err error
-
-		// TODO: Make this configurable
-		if isDev {
-			config, err = check.FromFile("./test_config.json")
-		} else {
-			config, err = check.FromFile("./config/config.json")
-		}
+		var specs []check.Spec
 
+		// ScalingPolicies owned by each team are the primary source of checks
+		policies, err := policy.List()
 		if err != nil {
-			logger.Error(err, "failure getting configuration from file")
-			panic(err.Error())
+			logger.Error(err, "failure listing scaling policies")
 		}
-		// For each configured check... calculate our current utilization against the goal
-		// We can iterate on the check, because only one can apply to a given
-		// target.  Any duplicate targets are ignored at the configuration layer.
-		for _, checkSpec := range config {
-			checkLogger := logger.WithValues("target", checkSpec.TargetKey(), "checkName", checkSpec.Name)
-			checkLogger.V(2).Info("checkSpec received")
-
-			var recommendations []float64
-
-			for _, rName := range check.SupportedResources() {
-				scaleFactor := checkSpec.ResourceScaler(rName)
-				checkLogger.V(1).Info("scaleFactor calculation", "scaleFactor", scaleFactor)
-				if scaleFactor != 0 {
-					scalerLogger := checkLogger.WithValues("resource", rName)
-					availableResource := utilization.CapacityByResource(rName)
-					percentage := utilization.PercentageByResource(rName)
-
-					usagePct := fmt.Sprintf("%.2f", percentage*100.0)
-					targetPct := fmt.Sprintf("%.2f", checkSpec.ResourceScaler(rName))
-					scalerLogger.V(2).Info("Percent utilization", "usage_pct", usagePct, "target_pct", targetPct)
-
-					newRecommendation := math.Ceil(float64(availableResource) / checkSpec.ResourceScaler(rName))
-					scalerLogger.V(2).Info("Scaling quotient", "available", availableResource, "scaler", checkSpec.ResourceScaler(rName), "calculatedReplicas", newRecommendation)
-
-					recommendations = append(recommendations, newRecommendation)
-				} else {
-					checkLogger.V(1).Info("Scaler does not apply", "resource", rName)
-				}
-			}
-
-			// New recommendedReplicas is the highest of all recommendations
-			// TODO: Make this behavior configurable, i.e. "max", "min", "geometric_mean"
-			var recommendedReplicas float64
-			for _, v := range recommendations {
-				recommendedReplicas = math.Max(recommendedReplicas, v)
-			}
-
-			currentReplicas, err := scaler.GetReplicas(checkSpec.Target)
+		owners := make(map[string]*v1alpha1.ScalingPolicy)
+		var owned []*v1alpha1.ScalingPolicy
+		for i := range policies {
+			p, checkSpec, err := policy.Decode(&policies[i])
 			if err != nil {
-				if errors.IsAlreadyExists(err) {
-					checkLogger.Error(err, "Target already exists", "target", checkSpec.TargetKey())
-				} else if errors.IsNotFound(err) {
-					checkLogger.Error(err, "Target doesn't exist", "target", checkSpec.TargetKey())
-				} else {
-					panic(err.Error())
-				}
+				logger.Error(err, "invalid scaling policy", "policy", checkSpec.Source)
+				reportStatus(logger, p, controller.Result{Err: err})
 				continue
 			}
+			owners[checkSpec.Source] = p
+			owned = append(owned, p)
+			resetStatus(p)
+			specs = append(specs, checkSpec)
+		}
 
-			checkLogger.Info("Current scale", "replica_count", currentReplicas)
-
-			if currentReplicas != int32(recommendedReplicas) {
-				// Recommend we do the upgrade, and if not DRYRUN, do it
-				checkLogger.Info("Recommended scaling (based on all inputs)", "action", fmt.Sprintf("%d=>%d", currentReplicas, int32(recommendedReplicas)))
-
-				if _, ok := os.LookupEnv("CRA_DRYRUN"); ok {
-					continue
-				}
+		if config := configWatcher.Current(); config != nil {
+			logger.V(1).Info("Using configuration", "generation", config.Generation, "hash", config.Hash, "loadedAt", config.LoadedAt)
+			specs = append(specs, config.Specs...)
+		}
 
-				oldReplicas, err := scaler.UpdateReplicas(checkSpec.Target, int32(recommendedReplicas))
-				if err != nil {
-					checkLogger.Error(err, "Error in UpdateReplicas")
-					continue
+		// Workloads may also opt in through annotations, but any target
+		// configured explicitly ignores its annotations
+		discovered, err := annotation.Discover()
+		if err != nil {
+			logger.Error(err, "failure discovering annotated targets")
+		}
+		specs = check.WithPrecedence(controller.ExpandZones(specs), discovered)
+
+		// For each configured target... calculate our current utilization against the goal.
+		// Every check of a target is reconciled together into a single recommendation.
+		for _, group := range check.GroupByTarget(specs) {
+			result := controller.Reconcile(group)
+			for _, c := range group.Checks {
+				if p, ok := owners[c.Source]; ok {
+					recordStatus(p, c, result)
 				}
-				checkLogger.Info("Updated target", "oldReplicas", oldReplicas, "newReplicas", recommendedReplicas)
 			}
 		}
+		for _, p := range owned {
+			writeStatus(logger, p)
+		}
 		if isDev {
 			// Running locally... don't sleep
 			logger.V(2).Info("Development mode, exiting....")
@@ -191,3 +193,59 @@ func main() {
 		time.Sleep(30 * time.Second)
 	}
 }
+
+// reportStatus writes the result of the policy's target to its status
+func reportStatus(logger logr.Logger, p *v1alpha1.ScalingPolicy, result controller.Result) {
+	resetStatus(p)
+	recordStatus(p, check.Spec{}, result)
+	writeStatus(logger, p)
+}
+
+// resetStatus clears the status of the policy for a new tick
+func resetStatus(p *v1alpha1.ScalingPolicy) {
+	p.Status = v1alpha1.ScalingPolicyStatus{
+		ObservedGeneration: p.Generation,
+		LastUpdateTime:     metav1.Now(),
+	}
+}
+
+// recordStatus records the result of the policy's target in its status, or
+// of the target of one zone for a per zone policy
+func recordStatus(p *v1alpha1.ScalingPolicy, c check.Spec, result controller.Result) {
+	var lastError string
+	if result.Err != nil {
+		lastError = result.Err.Error()
+	}
+	if c.Zone != "" {
+		p.Status.Zones = append(p.Status.Zones, v1alpha1.ZoneStatus{
+			Zone:               c.Zone,
+			Target:             c.Target.Name,
+			CurrentReplicas:    result.CurrentReplicas,
+			LastRecommendation: result.RecommendedReplicas,
+			LastError:          lastError,
+		})
+		return
+	}
+
+	p.Status.CurrentReplicas = result.CurrentReplicas
+	p.Status.LastRecommendation = result.RecommendedReplicas
+	p.Status.LastError = lastError
+	p.Status.HPA = result.HPA
+	for _, c := range result.Checks {
+		p.Status.Checks = append(p.Status.Checks, v1alpha1.CheckStatus{
+			Name:               c.Name,
+			Source:             c.Source,
+			LastRecommendation: c.RecommendedReplicas,
+			Bound:              string(c.Bound),
+			HeldBy:             c.HeldBy,
+			Schedule:           c.Schedule,
+		})
+	}
+}
+
+// writeStatus writes the recorded status of the policy
+func writeStatus(logger logr.Logger, p *v1alpha1.ScalingPolicy) {
+	if err := policy.UpdateStatus(p); err != nil {
+		logger.Error(err, "failure updating scaling policy status", "policy", fmt.Sprintf("%s/%s", p.Namespace, p.Name))
+	}
+}
@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/ryanmt/cluster-resource-autoscaler/api/v1alpha1"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/kubeapi"
+	"github.com/ryanmt/cluster-resource-autoscaler/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+)
+
+var logger logr.Logger
+var ctx context.Context
+
+func Init(initCtx context.Context) {
+	logger = logging.FromContextOrDiscard(initCtx)
+	ctx = initCtx
+}
+
+func client() dynamic.NamespaceableResourceInterface {
+	return kubeapi.DynamicClient().Resource(v1alpha1.ScalingPolicyResource)
+}
+
+// List returns the ScalingPolicies in every namespace, undecoded so that one
+// malformed policy can't keep the others from being used
+func List() ([]unstructured.Unstructured, error) {
+	u, err := client().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	logger.V(2).Info("Listed scaling policies", "count", len(u.Items))
+
+	return u.Items, nil
+}
+
+// Decode returns a listed policy along with the check.Spec it describes.  The
+// spec is decoded as strictly as a config file, so a misspelled or malformed
+// key is an error rather than ignored.  On error the policy still carries
+// enough to report the error in its status.
+func Decode(u *unstructured.Unstructured) (*v1alpha1.ScalingPolicy, check.Spec, error) {
+	p := &v1alpha1.ScalingPolicy{}
+	object := u.DeepCopy().UnstructuredContent()
+	delete(object, "spec")
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, p); err != nil {
+		p = &v1alpha1.ScalingPolicy{ObjectMeta: metav1.ObjectMeta{
+			Name:            u.GetName(),
+			Namespace:       u.GetNamespace(),
+			Generation:      u.GetGeneration(),
+			ResourceVersion: u.GetResourceVersion(),
+		}}
+		return p, check.Spec{Source: source(p)}, err
+	}
+
+	raw, err := json.Marshal(u.Object["spec"])
+	if err != nil {
+		return p, check.Spec{Source: source(p)}, err
+	}
+	if p.Spec, err = check.SpecFromJSON(raw, source(p)); err != nil {
+		return p, check.Spec{Source: source(p)}, err
+	}
+
+	spec, err := ToSpec(p)
+	return p, spec, err
+}
+
+func source(p *v1alpha1.ScalingPolicy) string {
+	return fmt.Sprintf("ScalingPolicy/%s/%s", p.Namespace, p.Name)
+}
+
+// UpdateStatus writes the status subresource of the given policy
+func UpdateStatus(p *v1alpha1.ScalingPolicy) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(p)
+	if err != nil {
+		return err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(v1alpha1.SchemeGroupVersion.WithKind("ScalingPolicy"))
+
+	_, err = client().Namespace(p.Namespace).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+	return err
+}
+
+// ToSpec returns the check.Spec described by a policy.  The target always lives
+// in the namespace of the policy, so teams can only scale their own workloads.
+func ToSpec(p *v1alpha1.ScalingPolicy) (check.Spec, error) {
+	spec := *p.Spec.DeepCopy()
+	spec.Source = source(p)
+	if spec.Name == "" {
+		spec.Name = p.Name
+	}
+
+	if spec.Target.Namespace == "" {
+		spec.Target.Namespace = p.Namespace
+	} else if spec.Target.Namespace != p.Namespace {
+		return spec, fmt.Errorf("target namespace %q must match the policy namespace %q", spec.Target.Namespace, p.Namespace)
+	}
+
+	return spec, check.Validate([]check.Spec{spec})
+}
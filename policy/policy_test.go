@@ -0,0 +1,201 @@
+package policy_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/api/v1alpha1"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/policy"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+func GiveMeAPolicy(targetNamespace string) *v1alpha1.ScalingPolicy {
+	return &v1alpha1.ScalingPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "team"},
+		Spec: check.Spec{
+			CPUPerReplica: resource.MustParse("16"),
+			Target:        check.ScalingTarget{Name: "nginx", Namespace: targetNamespace, Kind: "deployment"},
+		},
+	}
+}
+
+func TestToSpec(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        *v1alpha1.ScalingPolicy
+		wantNamespace string
+		wantErr       bool
+	}{
+		{"namespace defaults to the policy", GiveMeAPolicy(""), "team", false},
+		{"same namespace", GiveMeAPolicy("team"), "team", false},
+		{"other namespace", GiveMeAPolicy("kube-system"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := policy.ToSpec(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Target.Namespace != tt.wantNamespace {
+				t.Errorf("ToSpec() namespace = %v, want %v", got.Target.Namespace, tt.wantNamespace)
+			}
+			if got.Name != "nginx" {
+				t.Errorf("ToSpec() should default the name to the policy name, got %q", got.Name)
+			}
+		})
+	}
+}
+
+func TestUnstructuredRoundTrip(t *testing.T) {
+	p := GiveMeAPolicy("team")
+	p.Status.LastRecommendation = 4
+
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := u["spec"].(map[string]interface{})["cpuPerReplica"]; !ok {
+		t.Errorf("spec should be serialized with camelCase keys, got %v", u["spec"])
+	}
+
+	var got v1alpha1.ScalingPolicy
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(got.Spec, p.Spec) || got.Status.LastRecommendation != 4 {
+		t.Errorf("round trip = %+v, want %+v", got, p)
+	}
+}
+
+// GiveMeTheSchema returns the openAPIV3Schema of the ScalingPolicy CRD
+func GiveMeTheSchema(t *testing.T) map[string]interface{} {
+	data, err := os.ReadFile("../manifests/crd.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var crd struct {
+		Spec struct {
+			Versions []struct {
+				Schema struct {
+					OpenAPIV3Schema map[string]interface{} `json:"openAPIV3Schema"`
+				} `json:"schema"`
+			} `json:"versions"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		t.Fatal(err)
+	}
+	return crd.Spec.Versions[0].Schema.OpenAPIV3Schema
+}
+
+// prune drops every field the schema doesn't declare, as the API server does
+// when storing a custom resource
+func prune(value interface{}, schema map[string]interface{}) {
+	preserve, _ := schema["x-kubernetes-preserve-unknown-fields"].(bool)
+	properties, _ := schema["properties"].(map[string]interface{})
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			fieldSchema, ok := properties[key].(map[string]interface{})
+			switch {
+			case ok:
+				prune(field, fieldSchema)
+			case !preserve:
+				delete(v, key)
+			}
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for _, item := range v {
+				prune(item, items)
+			}
+		}
+	}
+}
+
+func TestSchemaRoundTrip(t *testing.T) {
+	p := GiveMeAPolicy("team")
+	p.Spec.Target = check.ScalingTarget{Name: "rollout", Kind: "Rollout", APIVersion: "argoproj.io/v1alpha1"}
+
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := GiveMeTheSchema(t)
+	prune(u["spec"], schema["properties"].(map[string]interface{})["spec"].(map[string]interface{}))
+
+	var stored v1alpha1.ScalingPolicy
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, &stored); err != nil {
+		t.Fatal(err)
+	}
+	got, err := policy.ToSpec(&stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := check.ScalingTarget{Name: "rollout", Namespace: "team", Kind: "Rollout", APIVersion: "argoproj.io/v1alpha1"}
+	if got.Target != want {
+		t.Errorf("ToSpec() target = %+v after the schema pruned the policy, want %+v", got.Target, want)
+	}
+	if got.CPUPerReplica.Cmp(p.Spec.CPUPerReplica) != 0 {
+		t.Errorf("ToSpec() cpuPerReplica = %v, want %v", got.CPUPerReplica.String(), p.Spec.CPUPerReplica.String())
+	}
+}
+
+// GiveMeAnUnstructuredPolicy returns a listed policy with the given spec
+func GiveMeAnUnstructuredPolicy(name string, spec map[string]interface{}) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": v1alpha1.SchemeGroupVersion.String(),
+		"kind":       "ScalingPolicy",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "team", "generation": int64(3)},
+		"spec":       spec,
+	}}
+}
+
+func TestDecode(t *testing.T) {
+	target := map[string]interface{}{"kind": "deployment", "name": "nginx"}
+	tests := []struct {
+		name      string
+		spec      map[string]interface{}
+		wantField string
+		wantErr   bool
+	}{
+		{"valid", map[string]interface{}{"cpuPerReplica": int64(16), "minReplicas": int64(2), "target": target}, "", false},
+		{"misspelled key", map[string]interface{}{"cpuPerReplica": "16", "minReplica": int64(5), "target": target}, "minReplica", true},
+		{"malformed quantity", map[string]interface{}{"cpuPerReplica": "sixteen", "target": target}, "cpuPerReplica", true},
+		{"invalid check", map[string]interface{}{"target": target}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := GiveMeAnUnstructuredPolicy("nginx", tt.spec)
+			p, got, err := policy.Decode(&u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if p.Name != "nginx" || p.Namespace != "team" || p.Generation != 3 {
+				t.Errorf("Decode() policy = %v/%v generation %v, want enough to report its status", p.Namespace, p.Name, p.Generation)
+			}
+			if got.Source != "ScalingPolicy/team/nginx" {
+				t.Errorf("Decode() source = %q", got.Source)
+			}
+			if tt.wantField != "" {
+				var verr check.ValidationError
+				if !errors.As(err, &verr) || len(verr) != 1 || verr[0].Field != tt.wantField {
+					t.Errorf("Decode() error = %v, want a problem with %s", err, tt.wantField)
+				}
+			}
+			if !tt.wantErr && (got.Target.Namespace != "team" || *got.MinReplicas != 2) {
+				t.Errorf("Decode() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package scaler
+
+import (
+	"encoding/json"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/kubeapi"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// FindHPA finds the HorizontalPodAutoscaler whose scaleTargetRef is the
+// target, nil when there is none
+func FindHPA(target check.ScalingTarget) (*autoscalingv1.HorizontalPodAutoscaler, error) {
+	hpas, err := kubeapi.APIClient().AutoscalingV1().HorizontalPodAutoscalers(target.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return MatchHPA(restMapper, hpas.Items, target)
+}
+
+// MatchHPA picks the HPA whose scaleTargetRef is the target, nil when there is
+// none.  Both kinds are resolved with the mapper before comparing them, so
+// short, plural and differently cased names of the same kind match.
+func MatchHPA(mapper meta.RESTMapper, hpas []autoscalingv1.HorizontalPodAutoscaler, target check.ScalingTarget) (*autoscalingv1.HorizontalPodAutoscaler, error) {
+	want, err := groupKind(mapper, target)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range hpas {
+		hpa := &hpas[i]
+		ref := hpa.Spec.ScaleTargetRef
+		if hpa.Namespace != target.Namespace || ref.Name != target.Name {
+			continue
+		}
+		got, err := groupKind(mapper, check.ScalingTarget{Name: ref.Name, Namespace: hpa.Namespace, Kind: ref.Kind, APIVersion: ref.APIVersion})
+		if err != nil {
+			// The HPA can't scale a kind the API server doesn't serve either
+			logger.V(1).Info("Ignoring HPA of an unknown kind", "hpa", hpa.Name, "apiVersion", ref.APIVersion, "kind", ref.Kind, "error", err.Error())
+			continue
+		}
+		if got == want {
+			return hpa, nil
+		}
+	}
+	return nil, nil
+}
+
+// groupKind resolves the kind of the target with the mapper
+func groupKind(mapper meta.RESTMapper, target check.ScalingTarget) (schema.GroupKind, error) {
+	gvr, err := resolveResource(mapper, target)
+	if err != nil {
+		return schema.GroupKind{}, err
+	}
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return schema.GroupKind{}, err
+	}
+	return gvk.GroupKind(), nil
+}
+
+// HPAMinReplicas is the minReplicas of the HPA, which defaults to 1
+func HPAMinReplicas(hpa *autoscalingv1.HorizontalPodAutoscaler) int32 {
+	if hpa.Spec.MinReplicas == nil {
+		return 1
+	}
+	return *hpa.Spec.MinReplicas
+}
+
+// UpdateHPA sets the minReplicas and maxReplicas of the HPA, patching only
+// those fields so the HPA's metrics are left alone
+func UpdateHPA(hpa *autoscalingv1.HorizontalPodAutoscaler, minReplicas, maxReplicas int32) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]int32{"minReplicas": minReplicas, "maxReplicas": maxReplicas},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = kubeapi.APIClient().AutoscalingV1().HorizontalPodAutoscalers(hpa.Namespace).Patch(ctx, hpa.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	logger.V(2).Info("HPA updated", "hpa", hpa.Name, "minReplicas", minReplicas, "maxReplicas", maxReplicas)
+	return nil
+}
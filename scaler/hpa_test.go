@@ -0,0 +1,87 @@
+package scaler_test
+
+import (
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/scaler"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/restmapper"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// GiveMeAMapper returns a mapper of a cluster serving Deployments and Argo
+// Rollouts, short names included, as discovery would
+func GiveMeAMapper() meta.RESTMapper {
+	resources := []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{
+			{Name: "deployments", SingularName: "deployment", Namespaced: true, Kind: "Deployment", ShortNames: []string{"deploy"}},
+		}},
+		{GroupVersion: "argoproj.io/v1alpha1", APIResources: []metav1.APIResource{
+			{Name: "rollouts", SingularName: "rollout", Namespaced: true, Kind: "Rollout", ShortNames: []string{"ro"}},
+		}},
+	}
+	discovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: resources}}
+	groups, err := restmapper.GetAPIGroupResources(discovery)
+	if err != nil {
+		panic(err)
+	}
+	return restmapper.NewShortcutExpander(restmapper.NewDiscoveryRESTMapper(groups), discovery)
+}
+
+// GiveMeAnHPA returns an HPA in the default namespace scaling the named target
+func GiveMeAnHPA(name, apiVersion, kind, target string) autoscalingv1.HorizontalPodAutoscaler {
+	return autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{APIVersion: apiVersion, Kind: kind, Name: target},
+		},
+	}
+}
+
+func TestMatchHPA(t *testing.T) {
+	hpas := []autoscalingv1.HorizontalPodAutoscaler{
+		GiveMeAnHPA("unknown", "example.com/v1", "Widget", "web"),
+		GiveMeAnHPA("other", "apps/v1", "Deployment", "other"),
+		GiveMeAnHPA("deployment", "apps/v1", "Deployment", "web"),
+		GiveMeAnHPA("rollout", "argoproj.io/v1alpha1", "Rollout", "canary"),
+	}
+
+	tests := []struct {
+		name   string
+		target check.ScalingTarget
+		want   string
+	}{
+		{"deployment", check.ScalingTarget{Kind: "deployment", Name: "web"}, "deployment"},
+		{"deployment by short name", check.ScalingTarget{Kind: "deploy", Name: "web"}, "deployment"},
+		{"deployment with its group", check.ScalingTarget{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"}, "deployment"},
+		{"rollout", check.ScalingTarget{APIVersion: "argoproj.io/v1alpha1", Kind: "Rollout", Name: "canary"}, "rollout"},
+		{"rollout by plural", check.ScalingTarget{APIVersion: "argoproj.io/v1alpha1", Kind: "rollouts", Name: "canary"}, "rollout"},
+		{"rollout by short name", check.ScalingTarget{APIVersion: "argoproj.io/v1alpha1", Kind: "ro", Name: "canary"}, "rollout"},
+		{"other kind of the same name", check.ScalingTarget{APIVersion: "argoproj.io/v1alpha1", Kind: "ro", Name: "web"}, ""},
+		{"no HPA", check.ScalingTarget{Kind: "deployment", Name: "canary"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.target.Namespace = "default"
+			got, err := scaler.MatchHPA(GiveMeAMapper(), hpas, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var name string
+			if got != nil {
+				name = got.Name
+			}
+			if name != tt.want {
+				t.Errorf("MatchHPA() = %q, want %q", name, tt.want)
+			}
+		})
+	}
+
+	if _, err := scaler.MatchHPA(GiveMeAMapper(), hpas, check.ScalingTarget{APIVersion: "example.com/v1", Kind: "Widget", Name: "web", Namespace: "default"}); err == nil {
+		t.Errorf("MatchHPA() should fail for a target of an unknown kind")
+	}
+}
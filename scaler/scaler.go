@@ -3,6 +3,9 @@ package scaler
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/ryanmt/cluster-resource-autoscaler/check"
@@ -10,7 +13,11 @@ import (
 	"github.com/ryanmt/cluster-resource-autoscaler/logging"
 
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	cacheddiscovery "k8s.io/client-go/discovery/cached/memory"
@@ -20,8 +27,17 @@ import (
 )
 
 var ctx context.Context
-var logger logr.Logger
+var logger logr.Logger = logr.Discard()
 var scaler scale.ScalesGetter
+var restMapper meta.RESTMapper
+var deferredMapper *restmapper.DeferredDiscoveryRESTMapper
+
+// discoveryRefreshInterval is how often at most a kind missing from the cached
+// discovery makes it reload, e.g. for a CRD installed after startup
+const discoveryRefreshInterval = time.Minute
+
+var discoveryMu sync.Mutex
+var discoveredAt time.Time
 
 func Init(initCtx context.Context) {
 	var err error
@@ -36,8 +52,11 @@ func Init(initCtx context.Context) {
 	}
 	cachedDiscoveryClient := cacheddiscovery.NewMemCacheClient(discoveryClient)
 
-	restMapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
-	restMapper.Reset()
+	deferredMapper = restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
+	deferredMapper.Reset()
+	discoveredAt = time.Now()
+	// Expand short names such as ro for Argo Rollouts
+	restMapper = restmapper.NewShortcutExpander(deferredMapper, cachedDiscoveryClient)
 	scaleKindResolver := scale.NewDiscoveryScaleKindResolver(discoveryClient)
 	scaler, err = scale.NewForConfig(config, restMapper, dynamic.LegacyAPIPathResolverFunc, scaleKindResolver)
 	if err != nil {
@@ -46,7 +65,10 @@ func Init(initCtx context.Context) {
 }
 
 func GetReplicas(target check.ScalingTarget) (int32, error) {
-	gr := lookupGroupResource(target)
+	gr, err := lookupGroupResource(target)
+	if err != nil {
+		return 0, err
+	}
 	currentScale, err := scaler.Scales(target.Namespace).Get(ctx, gr, target.Name, metav1.GetOptions{})
 	if err != nil {
 		return 0, err
@@ -57,7 +79,10 @@ func GetReplicas(target check.ScalingTarget) (int32, error) {
 }
 
 func UpdateReplicas(target check.ScalingTarget, desiredReplicas int32) (prevReplicas int32, err error) {
-	gr := lookupGroupResource(target)
+	gr, err := lookupGroupResource(target)
+	if err != nil {
+		return 0, err
+	}
 
 	s := &autoscalingv1.Scale{
 		ObjectMeta: metav1.ObjectMeta{
@@ -71,24 +96,89 @@ func UpdateReplicas(target check.ScalingTarget, desiredReplicas int32) (prevRepl
 
 	newScale, err := scaler.Scales(target.Namespace).Update(ctx, gr, s, metav1.UpdateOptions{})
 	if err != nil {
-		logger.Error(err, "WTH", "newScale", newScale, "gr", gr)
-		return newScale.Spec.Replicas, err
+		logger.Error(err, "Error updating the scale of the target", "target", target.Key(), "resource", gr.String(), "desiredReplicas", desiredReplicas)
+		return 0, err
 	}
 	logger.V(2).Info("Scaling complete", "scale", newScale)
 
 	return newScale.Status.Replicas, nil
 }
 
-func lookupGroupResource(target check.ScalingTarget) schema.GroupResource {
-	var group string = "apps"
-	// switch target.Kind {
-	// case "deployment":
-	//   group = "apps"
-	// case "replicaset":
-	//   group = "apps"
-	// case "statefulset":
-	//   group = "apps"
-	// }
-
-	return schema.ParseGroupResource(fmt.Sprintf("%s.%s", target.Kind, group))
+// PodTemplate fetches the pod template of the target, e.g. to find the nodes
+// its pods could be scheduled onto
+func PodTemplate(target check.ScalingTarget) (*corev1.PodTemplateSpec, error) {
+	gvr, err := lookupResource(target)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := kubeapi.DynamicClient().Resource(gvr).Namespace(target.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	template, found, err := unstructured.NestedMap(obj.Object, "spec", "template")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no pod template", target.Key())
+	}
+
+	var podTemplate corev1.PodTemplateSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(template, &podTemplate); err != nil {
+		return nil, err
+	}
+	return &podTemplate, nil
+}
+
+func lookupGroupResource(target check.ScalingTarget) (schema.GroupResource, error) {
+	gvr, err := lookupResource(target)
+	return gvr.GroupResource(), err
+}
+
+// lookupResource resolves the resource of the target through discovery, so
+// any kind exposing the scale subresource can be scaled.  Discovery is cached,
+// so a kind it doesn't know reloads it, at most every discoveryRefreshInterval.
+func lookupResource(target check.ScalingTarget) (schema.GroupVersionResource, error) {
+	gvr, err := resolveResource(restMapper, target)
+	if meta.IsNoMatchError(err) && refreshDiscovery() {
+		gvr, err = resolveResource(restMapper, target)
+	}
+	return gvr, err
+}
+
+// refreshDiscovery drops the cached discovery, unless it was loaded within
+// discoveryRefreshInterval, reporting whether it did
+func refreshDiscovery() bool {
+	discoveryMu.Lock()
+	defer discoveryMu.Unlock()
+	if time.Since(discoveredAt) < discoveryRefreshInterval {
+		return false
+	}
+	logger.V(1).Info("Reloading API discovery for a kind it didn't know")
+	deferredMapper.Reset()
+	discoveredAt = time.Now()
+	return true
+}
+
+// resolveResource resolves the resource of the target with the mapper.  With
+// an APIVersion the kind is mapped, falling back to resource names such as
+// short names, and otherwise the kind names a resource of the apps group.
+func resolveResource(mapper meta.RESTMapper, target check.ScalingTarget) (schema.GroupVersionResource, error) {
+	if target.APIVersion == "" {
+		return mapper.ResourceFor(schema.GroupVersionResource{Group: "apps", Resource: target.NormalizedKind()})
+	}
+
+	gv, err := schema.ParseGroupVersion(target.APIVersion)
+	if err != nil {
+		return schema.GroupVersionResource{}, err
+	}
+	mapping, err := mapper.RESTMapping(gv.WithKind(target.Kind).GroupKind(), gv.Version)
+	if err == nil {
+		return mapping.Resource, nil
+	}
+	if gvr, resourceErr := mapper.ResourceFor(gv.WithResource(strings.ToLower(target.Kind))); resourceErr == nil {
+		return gvr, nil
+	}
+	return schema.GroupVersionResource{}, err
 }
@@ -2,13 +2,18 @@ package utilization
 
 import (
 	"context"
-	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
 	"github.com/ryanmt/cluster-resource-autoscaler/kubeapi"
 	"github.com/ryanmt/cluster-resource-autoscaler/logging"
+	"gopkg.in/inf.v0"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"k8s.io/metrics/pkg/apis/metrics/v1beta1"
@@ -17,12 +22,12 @@ import (
 type MetricDatum struct {
 	Timestamp time.Time
 	Window    time.Duration
-	Value     int64
+	Value     resource.Quantity
 }
 
 type Metrics map[string]MetricDatum
 
-var logger logr.Logger
+var logger logr.Logger = logr.Discard()
 var ctx context.Context
 
 func Init(initCtx context.Context) {
@@ -30,44 +35,197 @@ func Init(initCtx context.Context) {
 	ctx = initCtx
 }
 
-// CapacityByResource current cluster capacity of given resource in cores or kilobytes
-func CapacityByResource(resource corev1.ResourceName) int64 {
-	n, err := kubeapi.APIClient().CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+// CapacityByResource current capacity of given resource across the nodes, in
+// the resource's own units (cores, bytes, ...)
+func CapacityByResource(rName corev1.ResourceName, nodes []corev1.Node) resource.Quantity {
+	allocatableResource := SumAllocatable(nodes, rName)
+	logger.V(2).Info("Node resources allocated", "resource", rName, "nodes", len(nodes), "value", allocatableResource.String())
+	return allocatableResource
+}
+
+// ListNodes lists the nodes matching the filter.  The label selector is
+// applied by the API server and the taint rules locally.
+func ListNodes(filter check.NodeFilter) ([]corev1.Node, error) {
+	n, err := kubeapi.APIClient().CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: filter.Selector})
 	if err != nil {
-		panic(err.Error())
+		return nil, err
+	}
+	return FilterNodes(n.Items, filter), nil
+}
+
+// FilterNodes keeps the nodes matching the filter
+func FilterNodes(nodes []corev1.Node, filter check.NodeFilter) []corev1.Node {
+	if filter.IsZero() {
+		return nodes
+	}
+
+	var matching []corev1.Node
+	for i := range nodes {
+		if filter.Matches(&nodes[i]) {
+			matching = append(matching, nodes[i])
+		}
 	}
-	var allocatableResource int64
+	return matching
+}
 
-	for _, node := range n.Items {
-		quantity := node.Status.Allocatable[resource]
-		allocatableResource += quantity.MilliValue()
+// EligibleNodes keeps the nodes which count at now under the rules
+func EligibleNodes(nodes []corev1.Node, rules check.Eligibility, now time.Time) []corev1.Node {
+	var eligible []corev1.Node
+	for i := range nodes {
+		if reason := rules.Ineligible(&nodes[i], now); reason != "" {
+			logger.V(2).Info("Ignoring ineligible node", "node", nodes[i].Name, "reason", reason)
+			continue
+		}
+		eligible = append(eligible, nodes[i])
 	}
+	return eligible
+}
+
+// Zones counts the distinct zones of the nodes, going by their
+// topology.kubernetes.io/zone label
+func Zones(nodes []corev1.Node) int {
+	return len(ZoneNames(nodes))
+}
 
-	logger.V(2).Info("Node resources allocated", "resource", resource, "value", allocatableResource)
-	return allocatableResource / 1000
+// ZoneNames lists the distinct zones of the nodes in order, going by their
+// topology.kubernetes.io/zone label
+func ZoneNames(nodes []corev1.Node) []string {
+	seen := make(map[string]bool)
+	var zones []string
+	for _, node := range nodes {
+		if zone, ok := node.Labels[corev1.LabelTopologyZone]; ok && !seen[zone] {
+			seen[zone] = true
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
 }
 
-func UtilizationByResource(resource corev1.ResourceName) int64 {
+// SumAllocatable adds up the allocatable amount of a resource across nodes.
+// The sum is kept as a Quantity, so it never overflows or loses precision.  A
+// prefix resource such as hugepages- sums every resource starting with it.
+func SumAllocatable(nodes []corev1.Node, rName corev1.ResourceName) resource.Quantity {
+	var allocatableResource resource.Quantity
+
+	for _, node := range nodes {
+		allocatableResource.Add(sumMatching(node.Status.Allocatable, rName))
+	}
+
+	return allocatableResource
+}
+
+// sumMatching adds up every entry of a resource list counting towards rName
+func sumMatching(list corev1.ResourceList, rName corev1.ResourceName) resource.Quantity {
+	var total resource.Quantity
+	for actual, quantity := range list {
+		if check.ResourceMatches(rName, actual) {
+			total.Add(quantity)
+		}
+	}
+	return total
+}
+
+// UtilizationByResource current usage of given resource across the nodes
+func UtilizationByResource(rName corev1.ResourceName, nodes []corev1.Node) resource.Quantity {
+	var nodeResourceUsage resource.Quantity
+
 	nodeMetrics, err := kubeapi.MetricClient().NodeMetricses().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		fmt.Printf("Error getting Metrics: %v\n", err.Error())
+		logger.Error(err, "Error getting Metrics")
+		return nodeResourceUsage
 	}
 
-	nMetrics := getMetrics(nodeMetrics.Items, resource)
-
-	var nodeResourceUsage int64
+	nMetrics := onlyNodes(getMetrics(nodeMetrics.Items, rName), nodes)
 
 	for k, m := range nMetrics {
-		logger.V(3).Info("Found node metric", "resource", resource.String(), "node", k, "value", m.Value)
-		nodeResourceUsage += m.Value
+		logger.V(3).Info("Found node metric", "resource", rName.String(), "node", k, "value", m.Value.String())
+		nodeResourceUsage.Add(m.Value)
 	}
-	logger.V(2).Info("Node utilization", "resource", resource, "value", nodeResourceUsage)
+	logger.V(2).Info("Node utilization", "resource", rName, "value", nodeResourceUsage.String())
 
 	return nodeResourceUsage
 }
 
-func PercentageByResource(resource corev1.ResourceName) float64 {
-	return float64(UtilizationByResource(resource)) / float64(CapacityByResource(resource))
+// UsageByResource current usage of given resource across the nodes, going by
+// metrics.k8s.io.  ok is false unless every node has a metric no older than
+// maxAge, since a partial sum would understate the usage.
+func UsageByResource(rName corev1.ResourceName, nodes []corev1.Node, maxAge time.Duration) (usage resource.Quantity, ok bool) {
+	nodeMetrics, err := kubeapi.MetricClient().NodeMetricses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logger.Error(err, "Error getting Metrics")
+		return usage, false
+	}
+
+	usage, missing := FreshUsage(getMetrics(nodeMetrics.Items, rName), nodes, time.Now(), maxAge)
+	if len(missing) > 0 {
+		logger.V(1).Info("Missing or stale node metrics", "resource", rName, "nodes", missing, "maxAge", maxAge.String())
+		return usage, false
+	}
+	logger.V(2).Info("Node usage", "resource", rName, "nodes", len(nodes), "value", usage.String())
+	return usage, true
+}
+
+// FreshUsage sums the metrics of the nodes, also naming the nodes without a
+// metric taken within maxAge of now
+func FreshUsage(metrics Metrics, nodes []corev1.Node, now time.Time, maxAge time.Duration) (resource.Quantity, []string) {
+	var usage resource.Quantity
+	var missing []string
+	for _, node := range nodes {
+		m, ok := metrics[node.Name]
+		if !ok || now.Sub(m.Timestamp) > maxAge {
+			missing = append(missing, node.Name)
+			continue
+		}
+		usage.Add(m.Value)
+	}
+	return usage, missing
+}
+
+func PercentageByResource(rName corev1.ResourceName, nodes []corev1.Node) float64 {
+	return Ratio(UtilizationByResource(rName, nodes), CapacityByResource(rName, nodes))
+}
+
+// Quotient is how many replicas capacity warrants at perReplica each, with
+// the fraction of the last one, e.g. 4.2.  Its floor and ceiling are exact,
+// so rounding it agrees with dividing the quantities exactly, however large.
+func Quotient(capacity, perReplica resource.Quantity) float64 {
+	if perReplica.Sign() <= 0 {
+		return 0
+	}
+
+	whole := new(inf.Dec).QuoRound(capacity.AsDec(), perReplica.AsDec(), 0, inf.RoundDown)
+	replicas, ok := whole.Unscaled()
+	if !ok {
+		return math.MaxInt64
+	}
+	remainder := new(inf.Dec).Sub(capacity.AsDec(), new(inf.Dec).Mul(whole, perReplica.AsDec()))
+	if remainder.Sign() == 0 {
+		return float64(replicas)
+	}
+
+	// The fraction is strictly between the whole replicas, however small or
+	// close to one it is
+	fraction, err := strconv.ParseFloat(new(inf.Dec).QuoRound(remainder, perReplica.AsDec(), 9, inf.RoundHalfEven).String(), 64)
+	if err != nil {
+		return float64(replicas)
+	}
+	lower, upper := math.Nextafter(float64(replicas), math.Inf(1)), math.Nextafter(float64(replicas)+1, 0)
+	return math.Min(math.Max(float64(replicas)+fraction, lower), upper)
+}
+
+// Ratio divides two quantities of the same resource, e.g. usage by capacity
+func Ratio(numerator, denominator resource.Quantity) float64 {
+	if denominator.IsZero() {
+		return 0
+	}
+
+	quotient := new(inf.Dec).QuoRound(numerator.AsDec(), denominator.AsDec(), 9, inf.RoundHalfEven)
+	ratio, err := strconv.ParseFloat(quotient.String(), 64)
+	if err != nil {
+		return 0
+	}
+	return ratio
 }
 
 // resourceNames
@@ -83,18 +241,39 @@ func ResourceNames() []corev1.ResourceName {
 	}
 }
 
-func getMetrics(rawNodeMetrics []v1beta1.NodeMetrics, resource corev1.ResourceName) Metrics {
+// hasMatching reports whether a resource list has any entry counting towards rName
+func hasMatching(list corev1.ResourceList, rName corev1.ResourceName) bool {
+	for actual := range list {
+		if check.ResourceMatches(rName, actual) {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyNodes keeps the metrics of the given nodes
+func onlyNodes(metrics Metrics, nodes []corev1.Node) Metrics {
+	res := make(Metrics, len(nodes))
+	for _, node := range nodes {
+		if m, ok := metrics[node.Name]; ok {
+			res[node.Name] = m
+		}
+	}
+	return res
+}
+
+func getMetrics(rawNodeMetrics []v1beta1.NodeMetrics, rName corev1.ResourceName) Metrics {
 	res := make(Metrics, len(rawNodeMetrics))
 	for _, m := range rawNodeMetrics {
-		resValue, found := m.Usage[resource]
+		resValue, found := sumMatching(m.Usage, rName), hasMatching(m.Usage, rName)
 		if !found {
-			logger.V(2).Info("Missing resource metric", "resourceName", resource.String(), "namespace", m.Namespace, "name", m.Name)
-			break
+			logger.V(2).Info("Missing resource metric", "resourceName", rName.String(), "namespace", m.Namespace, "name", m.Name)
+			continue
 		}
 		res[m.Name] = MetricDatum{
 			Timestamp: m.Timestamp.Time,
 			Window:    m.Window.Duration,
-			Value:     resValue.Value(),
+			Value:     resValue,
 		}
 	}
 	return res
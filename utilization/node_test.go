@@ -0,0 +1,236 @@
+package utilization_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/utilization"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func GiveMeANode(cpu, memory string) corev1.Node {
+	return corev1.Node{
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+		},
+	}
+}
+
+func TestSumAllocatable(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodes    []corev1.Node
+		resource corev1.ResourceName
+		want     string
+	}{
+		{"no nodes", nil, corev1.ResourceCPU, "0"},
+		{"fractional cores", []corev1.Node{GiveMeANode("3500m", "1Gi"), GiveMeANode("250m", "1Gi")}, corev1.ResourceCPU, "3750m"},
+		{"memory in bytes", []corev1.Node{GiveMeANode("1", "1Gi"), GiveMeANode("1", "512Mi")}, corev1.ResourceMemory, "1536Mi"},
+		// Summing milli-units of this much memory would overflow an int64
+		{"huge memory", []corev1.Node{GiveMeANode("1", "6Pi"), GiveMeANode("1", "6Pi")}, corev1.ResourceMemory, "12Pi"},
+		{"missing resource", []corev1.Node{GiveMeANode("1", "1Gi")}, corev1.ResourceEphemeralStorage, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utilization.SumAllocatable(tt.nodes, tt.resource)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("SumAllocatable() = %v, want %v", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestQuotient(t *testing.T) {
+	tests := []struct {
+		name       string
+		capacity   string
+		perReplica string
+		want       float64
+		wantFloor  float64
+		wantCeil   float64
+	}{
+		{"exact", "64", "16", 4, 4, 4},
+		{"fraction", "4200m", "1", 4.2, 4, 5},
+		{"millicores", "7", "500m", 14, 14, 14},
+		{"fractional capacity", "3750m", "1", 3.75, 3, 4},
+		{"decimal exponent", "1e12", "100e9", 10, 10, 10},
+		{"binary memory", "1050Gi", "100Gi", 10.5, 10, 11},
+		{"one byte over", "1073741825", "1Gi", 1, 1, 2},
+		{"one byte short", "1073741823", "1Gi", 1, 0, 1},
+		{"huge memory", "13510798882111489", "12Pi", 1, 1, 2},
+		{"no capacity", "0", "16", 0, 0, 0},
+		{"no scaler", "64", "0", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utilization.Quotient(resource.MustParse(tt.capacity), resource.MustParse(tt.perReplica))
+			if math.Abs(got-tt.want) > 1e-6 || math.Floor(got) != tt.wantFloor || math.Ceil(got) != tt.wantCeil {
+				t.Errorf("Quotient(%v, %v) = %v, want %v between %v and %v", tt.capacity, tt.perReplica, got, tt.want, tt.wantFloor, tt.wantCeil)
+			}
+		})
+	}
+}
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		name        string
+		numerator   string
+		denominator string
+		want        float64
+	}{
+		{"half", "8", "16", 0.5},
+		{"mixed units", "500m", "2", 0.25},
+		{"memory", "3Gi", "12Gi", 0.25},
+		{"zero capacity", "1", "0", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utilization.Ratio(resource.MustParse(tt.numerator), resource.MustParse(tt.denominator))
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Ratio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSumAllocatable_Extended(t *testing.T) {
+	node := func(resources corev1.ResourceList) corev1.Node {
+		return corev1.Node{Status: corev1.NodeStatus{Allocatable: resources}}
+	}
+	nodes := []corev1.Node{
+		node(corev1.ResourceList{"hugepages-2Mi": resource.MustParse("1Gi"), "hugepages-1Gi": resource.MustParse("2Gi"), "example.com/fpga": resource.MustParse("2")}),
+		node(corev1.ResourceList{"hugepages-2Mi": resource.MustParse("512Mi"), corev1.ResourcePods: resource.MustParse("110")}),
+	}
+	tests := []struct {
+		resource corev1.ResourceName
+		want     string
+	}{
+		{corev1.ResourceHugePagesPrefix, "3584Mi"},
+		{"hugepages-2Mi", "1536Mi"},
+		{"example.com/fpga", "2"},
+		{corev1.ResourcePods, "110"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.resource), func(t *testing.T) {
+			got := utilization.SumAllocatable(nodes, tt.resource)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("SumAllocatable() = %v, want %v", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestFilterNodes(t *testing.T) {
+	pool := func(name, pool string, taints ...corev1.Taint) corev1.Node {
+		node := GiveMeANode("4", "16Gi")
+		node.Name = name
+		node.Labels = map[string]string{"pool": pool}
+		node.Spec.Taints = taints
+		return node
+	}
+	nodes := []corev1.Node{
+		pool("web-1", "web"),
+		pool("batch-1", "batch"),
+		pool("batch-2", "batch", corev1.Taint{Key: "spot", Effect: corev1.TaintEffectNoSchedule}),
+	}
+
+	tests := []struct {
+		name   string
+		filter check.NodeFilter
+		want   string
+	}{
+		{"every node", check.NodeFilter{}, "12"},
+		{"one pool", check.NodeFilter{Selector: "pool=batch"}, "8"},
+		{"one pool without spot", check.NodeFilter{Selector: "pool=batch", ExcludeTaints: []check.TaintRule{{Key: "spot"}}}, "4"},
+		{"only spot", check.NodeFilter{IncludeTaints: []check.TaintRule{{Key: "spot"}}}, "4"},
+		{"no match", check.NodeFilter{Selector: "pool=gpu"}, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utilization.SumAllocatable(utilization.FilterNodes(nodes, tt.filter), corev1.ResourceCPU)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("capacity of filtered nodes = %v, want %v", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestFreshUsage(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	nodes := []corev1.Node{GiveMeANode("16", "64Gi"), GiveMeANode("16", "64Gi")}
+	nodes[0].Name, nodes[1].Name = "a", "b"
+	metric := func(value string, age time.Duration) utilization.MetricDatum {
+		return utilization.MetricDatum{Timestamp: now.Add(-age), Window: 30 * time.Second, Value: resource.MustParse(value)}
+	}
+
+	tests := []struct {
+		name        string
+		metrics     utilization.Metrics
+		want        string
+		wantMissing []string
+	}{
+		{"fresh", utilization.Metrics{"a": metric("3500m", time.Minute), "b": metric("500m", 0)}, "4", nil},
+		{"idle node", utilization.Metrics{"a": metric("3", time.Minute), "b": metric("0", time.Minute)}, "3", nil},
+		{"missing node", utilization.Metrics{"a": metric("3", time.Minute)}, "3", []string{"b"}},
+		{"stale node", utilization.Metrics{"a": metric("3", time.Minute), "b": metric("1", time.Hour)}, "3", []string{"b"}},
+		{"other nodes ignored", utilization.Metrics{"a": metric("1", 0), "b": metric("1", 0), "c": metric("8", 0)}, "2", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := utilization.FreshUsage(tt.metrics, nodes, now, 5*time.Minute)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 || !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("FreshUsage() = %v, %v, want %v, %v", got.String(), missing, want.String(), tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestEligibleNodes(t *testing.T) {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	ready := corev1.NodeCondition{Type: corev1.NodeReady, Status: corev1.ConditionTrue}
+
+	var nodes []corev1.Node
+	for _, name := range []string{"a", "b", "c"} {
+		n := GiveMeANode("8", "32Gi")
+		n.Name = name
+		n.Status.Conditions = []corev1.NodeCondition{ready}
+		nodes = append(nodes, n)
+	}
+	// Draining b during an upgrade
+	nodes[1].Spec.Unschedulable = true
+
+	eligible := utilization.EligibleNodes(nodes, check.Eligibility{}, now)
+	got := utilization.CapacityByResource(corev1.ResourceCPU, eligible)
+	if want := resource.MustParse("16"); got.Cmp(want) != 0 {
+		t.Errorf("CapacityByResource() = %v, want %v without the cordoned node", got.String(), want.String())
+	}
+
+	eligible = utilization.EligibleNodes(nodes, check.Eligibility{IncludeUnschedulable: true}, now)
+	if len(eligible) != 3 {
+		t.Errorf("EligibleNodes() = %v nodes, want all 3", len(eligible))
+	}
+}
+
+func TestZones(t *testing.T) {
+	var nodes []corev1.Node
+	for _, zone := range []string{"eu-west-1b", "eu-west-1a", "eu-west-1a", ""} {
+		n := GiveMeANode("8", "32Gi")
+		if zone != "" {
+			n.Labels = map[string]string{corev1.LabelTopologyZone: zone}
+		}
+		nodes = append(nodes, n)
+	}
+	if got := utilization.Zones(nodes); got != 2 {
+		t.Errorf("Zones() = %v, want 2", got)
+	}
+	if got, want := utilization.ZoneNames(nodes), []string{"eu-west-1a", "eu-west-1b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ZoneNames() = %v, want %v", got, want)
+	}
+}
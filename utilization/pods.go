@@ -0,0 +1,65 @@
+package utilization
+
+import (
+	"github.com/ryanmt/cluster-resource-autoscaler/check"
+	"github.com/ryanmt/cluster-resource-autoscaler/kubeapi"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// scheduledPods selects the pods which are bound to a node and still running
+// or about to, leaving the rest of the filtering to SumRequests
+const scheduledPods = "spec.nodeName!=,status.phase!=Succeeded,status.phase!=Failed"
+
+// ListPods lists the pods matching the filter which are scheduled onto a node,
+// once per check so each of its resources is summed from the same pods
+func ListPods(filter check.PodFilter) ([]corev1.Pod, error) {
+	pods, err := kubeapi.APIClient().CoreV1().Pods(filter.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: filter.Selector,
+		FieldSelector: scheduledPods,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.V(2).Info("Scheduled pods", "namespace", filter.Namespace, "selector", filter.Selector, "pods", len(pods.Items))
+	return pods.Items, nil
+}
+
+// SumRequests adds up the requests of the non-terminal pods scheduled onto
+// any of the nodes
+func SumRequests(pods []corev1.Pod, nodes []corev1.Node, rName corev1.ResourceName) resource.Quantity {
+	onNodes := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		onNodes[node.Name] = true
+	}
+
+	var requested resource.Quantity
+	for i := range pods {
+		pod := &pods[i]
+		if !onNodes[pod.Spec.NodeName] || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		requested.Add(PodRequests(pod, rName))
+	}
+	return requested
+}
+
+// PodRequests is what the scheduler reserves for a pod: the larger of the sum
+// of its containers' requests and its largest init container request, plus
+// the pod overhead
+func PodRequests(pod *corev1.Pod, rName corev1.ResourceName) resource.Quantity {
+	var containers resource.Quantity
+	for _, c := range pod.Spec.Containers {
+		containers.Add(sumMatching(c.Resources.Requests, rName))
+	}
+
+	for _, c := range pod.Spec.InitContainers {
+		if init := sumMatching(c.Resources.Requests, rName); init.Cmp(containers) > 0 {
+			containers = init
+		}
+	}
+
+	containers.Add(sumMatching(pod.Spec.Overhead, rName))
+	return containers
+}
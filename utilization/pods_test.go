@@ -0,0 +1,74 @@
+package utilization_test
+
+import (
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/utilization"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func GiveMeAContainer(cpu string) corev1.Container {
+	return corev1.Container{Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+	}}
+}
+
+func GiveMeAPod(node string, phase corev1.PodPhase, cpus ...string) corev1.Pod {
+	pod := corev1.Pod{
+		Spec:   corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	for _, cpu := range cpus {
+		pod.Spec.Containers = append(pod.Spec.Containers, GiveMeAContainer(cpu))
+	}
+	return pod
+}
+
+func TestPodRequests(t *testing.T) {
+	withInit := GiveMeAPod("a", corev1.PodRunning, "500m", "250m")
+	withInit.Spec.InitContainers = []corev1.Container{GiveMeAContainer("2"), GiveMeAContainer("100m")}
+	withOverhead := GiveMeAPod("a", corev1.PodRunning, "1")
+	withOverhead.Spec.Overhead = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")}
+	smallInit := GiveMeAPod("a", corev1.PodRunning, "1", "1")
+	smallInit.Spec.InitContainers = []corev1.Container{GiveMeAContainer("1500m")}
+
+	tests := []struct {
+		name     string
+		pod      corev1.Pod
+		resource corev1.ResourceName
+		want     string
+	}{
+		{"containers add up", GiveMeAPod("a", corev1.PodRunning, "500m", "250m"), corev1.ResourceCPU, "750m"},
+		{"larger init container", withInit, corev1.ResourceCPU, "2"},
+		{"smaller init container", smallInit, corev1.ResourceCPU, "2"},
+		{"overhead", withOverhead, corev1.ResourceCPU, "1250m"},
+		{"no requests", GiveMeAPod("a", corev1.PodRunning, "1"), corev1.ResourceMemory, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := utilization.PodRequests(&tt.pod, tt.resource)
+			if want := resource.MustParse(tt.want); got.Cmp(want) != 0 {
+				t.Errorf("PodRequests() = %v, want %v", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestSumRequests(t *testing.T) {
+	nodes := []corev1.Node{GiveMeANode("16", "64Gi"), GiveMeANode("16", "64Gi")}
+	nodes[0].Name, nodes[1].Name = "a", "b"
+	pods := []corev1.Pod{
+		GiveMeAPod("a", corev1.PodRunning, "1"),
+		GiveMeAPod("b", corev1.PodPending, "2"),
+		GiveMeAPod("b", corev1.PodSucceeded, "4"),
+		GiveMeAPod("b", corev1.PodFailed, "8"),
+		GiveMeAPod("", corev1.PodPending, "16"),
+		GiveMeAPod("c", corev1.PodRunning, "32"),
+	}
+
+	got := utilization.SumRequests(pods, nodes, corev1.ResourceCPU)
+	if want := resource.MustParse("3"); got.Cmp(want) != 0 {
+		t.Errorf("SumRequests() = %v, want %v from the non-terminal pods on the nodes", got.String(), want.String())
+	}
+}
@@ -0,0 +1,131 @@
+package utilization
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SchedulableNodes keeps the nodes a pod with the given spec could be
+// scheduled onto, judging by its node selector, required node affinity and
+// tolerations.  Platform constraints such as kubernetes.io/os and
+// kubernetes.io/arch are node labels, so they are covered by the first two.
+func SchedulableNodes(nodes []corev1.Node, pod *corev1.PodSpec) []corev1.Node {
+	var schedulable []corev1.Node
+	for i := range nodes {
+		if Schedulable(&nodes[i], pod) {
+			schedulable = append(schedulable, nodes[i])
+		}
+	}
+	return schedulable
+}
+
+// Schedulable reports whether a pod with the given spec could be scheduled
+// onto the node, ignoring how much room is left on it
+func Schedulable(node *corev1.Node, pod *corev1.PodSpec) bool {
+	if !labels.SelectorFromSet(pod.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+
+	if affinity := pod.Affinity; affinity != nil && affinity.NodeAffinity != nil {
+		required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if required != nil && !matchesNodeSelector(node, required) {
+			return false
+		}
+	}
+
+	return toleratesNode(node, pod.Tolerations)
+}
+
+// matchesNodeSelector reports whether any of the terms matches the node.  As
+// in the scheduler, a selector without terms matches nothing.
+func matchesNodeSelector(node *corev1.Node, selector *corev1.NodeSelector) bool {
+	for _, term := range selector.NodeSelectorTerms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		if matchesTerm(node, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesTerm reports whether the node satisfies every requirement of the term
+func matchesTerm(node *corev1.Node, term corev1.NodeSelectorTerm) bool {
+	for _, req := range term.MatchExpressions {
+		value, ok := node.Labels[req.Key]
+		if !matchesRequirement(req, value, ok) {
+			return false
+		}
+	}
+	for _, req := range term.MatchFields {
+		// metadata.name is the only field the scheduler supports
+		if req.Key != "metadata.name" || !matchesRequirement(req, node.Name, true) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesRequirement(req corev1.NodeSelectorRequirement, value string, exists bool) bool {
+	switch req.Operator {
+	case corev1.NodeSelectorOpIn:
+		return exists && containsString(req.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !exists || !containsString(req.Values, value)
+	case corev1.NodeSelectorOpExists:
+		return exists
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !exists
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !exists || len(req.Values) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		bound, err := strconv.ParseInt(req.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if req.Operator == corev1.NodeSelectorOpGt {
+			return actual > bound
+		}
+		return actual < bound
+	}
+	return false
+}
+
+// toleratesNode reports whether every taint keeping pods off the node is
+// tolerated.  PreferNoSchedule taints only discourage scheduling.
+func toleratesNode(node *corev1.Node, tolerations []corev1.Toleration) bool {
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range tolerations {
+			if tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
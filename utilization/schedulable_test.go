@@ -0,0 +1,66 @@
+package utilization_test
+
+import (
+	"testing"
+
+	"github.com/ryanmt/cluster-resource-autoscaler/utilization"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSchedulable(t *testing.T) {
+	node := func(labels map[string]string, taints ...corev1.Taint) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: labels},
+			Spec:       corev1.NodeSpec{Taints: taints},
+		}
+	}
+	requireNodes := func(terms ...corev1.NodeSelectorTerm) *corev1.Affinity {
+		return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: terms},
+		}}
+	}
+	expr := func(key string, op corev1.NodeSelectorOperator, values ...string) corev1.NodeSelectorTerm {
+		return corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: key, Operator: op, Values: values}}}
+	}
+
+	linuxAmd64 := map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "amd64", "cpus": "16"}
+	linuxArm64 := map[string]string{"kubernetes.io/os": "linux", "kubernetes.io/arch": "arm64", "cpus": "64"}
+	windows := map[string]string{"kubernetes.io/os": "windows", "kubernetes.io/arch": "amd64"}
+	windowsTaint := corev1.Taint{Key: "os", Value: "windows", Effect: corev1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name string
+		node *corev1.Node
+		pod  corev1.PodSpec
+		want bool
+	}{
+		{"no constraints", node(linuxAmd64), corev1.PodSpec{}, true},
+		{"node selector matches", node(linuxAmd64), corev1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/os": "linux"}}, true},
+		{"linux pod on windows", node(windows), corev1.PodSpec{NodeSelector: map[string]string{"kubernetes.io/os": "linux"}}, false},
+		{"affinity In", node(linuxArm64), corev1.PodSpec{Affinity: requireNodes(expr("kubernetes.io/arch", corev1.NodeSelectorOpIn, "amd64"))}, false},
+		{"affinity terms are ORed", node(linuxArm64), corev1.PodSpec{Affinity: requireNodes(
+			expr("kubernetes.io/arch", corev1.NodeSelectorOpIn, "amd64"),
+			expr("kubernetes.io/arch", corev1.NodeSelectorOpIn, "arm64"),
+		)}, true},
+		{"affinity NotIn missing label", node(linuxAmd64), corev1.PodSpec{Affinity: requireNodes(expr("pool", corev1.NodeSelectorOpNotIn, "batch"))}, true},
+		{"affinity Exists", node(linuxAmd64), corev1.PodSpec{Affinity: requireNodes(expr("pool", corev1.NodeSelectorOpExists))}, false},
+		{"affinity DoesNotExist", node(linuxAmd64), corev1.PodSpec{Affinity: requireNodes(expr("pool", corev1.NodeSelectorOpDoesNotExist))}, true},
+		{"affinity Gt", node(linuxArm64), corev1.PodSpec{Affinity: requireNodes(expr("cpus", corev1.NodeSelectorOpGt, "32"))}, true},
+		{"affinity Lt", node(linuxArm64), corev1.PodSpec{Affinity: requireNodes(expr("cpus", corev1.NodeSelectorOpLt, "32"))}, false},
+		{"affinity by name", node(linuxAmd64), corev1.PodSpec{Affinity: requireNodes(corev1.NodeSelectorTerm{
+			MatchFields: []corev1.NodeSelectorRequirement{{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-a"}}},
+		})}, true},
+		{"affinity without terms", node(linuxAmd64), corev1.PodSpec{Affinity: requireNodes()}, false},
+		{"untolerated taint", node(windows, windowsTaint), corev1.PodSpec{}, false},
+		{"tolerated taint", node(windows, windowsTaint), corev1.PodSpec{Tolerations: []corev1.Toleration{{Key: "os", Operator: corev1.TolerationOpEqual, Value: "windows"}}}, true},
+		{"prefer no schedule", node(linuxAmd64, corev1.Taint{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule}), corev1.PodSpec{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := utilization.Schedulable(tt.node, &tt.pod); got != tt.want {
+				t.Errorf("Schedulable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}